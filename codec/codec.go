@@ -0,0 +1,373 @@
+// Package codec encodes and decodes RIPv1/RIPv2 packets (RFC 1058, RFC 2453)
+// including plain-text and keyed MD5 (RFC 4822) authentication.
+package codec
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	HeaderSize = 4
+	EntrySize  = 20
+	KeySize    = 16
+	MaxEntries = 25
+)
+
+// Commands
+const (
+	Request  = 1
+	Response = 2
+)
+
+// Address family identifiers
+const (
+	AFIGiveAll = 0
+	AFIIPv4    = 2
+	AFIAuth    = 0xffff
+)
+
+// Authentication types
+const (
+	AuthNone    = 0
+	AuthTrailer = 1
+	AuthPlain   = 2
+	AuthHash    = 3
+)
+
+var (
+	ErrShort        = errors.New("packet shorter than header")
+	ErrLength       = errors.New("packet length is not a multiple of entry size")
+	ErrEntryCount   = errors.New("too many route entries")
+	ErrCommand      = errors.New("unknown command")
+	ErrVersion      = errors.New("unsupported version")
+	ErrMustBeZero   = errors.New("must be zero field is set")
+	ErrAuthType     = errors.New("unsupported authentication type")
+	ErrAuthPosition = errors.New("authentication entry is not first")
+	ErrAuthLength   = errors.New("malformed authentication data length")
+	ErrTrailer      = errors.New("malformed authentication trailer")
+	ErrKeyLength    = errors.New("authentication key longer than 16 bytes")
+	ErrAuth         = errors.New("authentication failed")
+)
+
+// ParseError describes malformed input, Err is one of Err* values
+type ParseError struct {
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("malformed packet at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseErr(offset int, err error) error {
+	return &ParseError{Offset: offset, Err: err}
+}
+
+// Header is the fixed packet header
+type Header struct {
+	Command uint8
+	Version uint8
+	Zero    uint16
+}
+
+// RouteEntry is a single route table entry, RIPv1 leaves RouteTag, Mask and NextHop zero
+type RouteEntry struct {
+	AFI      uint16
+	RouteTag uint16
+	Network  uint32
+	Mask     uint32
+	NextHop  uint32
+	Metric   uint32
+}
+
+// Auth holds authentication data, Key is the password for AuthPlain
+// and the digest taken from trailer for AuthHash. DataLen is Auth Data Len
+// of AuthHash, RFC 4822 says 16 while many peers send 20, zero encodes as 20
+type Auth struct {
+	Type    uint16
+	KeyID   uint8
+	DataLen uint8
+	SQN     uint32
+	Key     [KeySize]byte
+}
+
+// Packet is a decoded RIP packet
+type Packet struct {
+	Header
+	Auth    Auth
+	Entries []RouteEntry
+}
+
+// Unmarshal decodes packet of at most MaxEntries route entries,
+// any malformed input results in *ParseError
+func Unmarshal(b []byte) (*Packet, error) {
+	return UnmarshalLimit(b, MaxEntries)
+}
+
+// UnmarshalLimit is Unmarshal with custom route entries limit
+func UnmarshalLimit(b []byte, max int) (*Packet, error) {
+	p := &Packet{}
+	if err := UnmarshalInto(p, b, max); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// UnmarshalInto decodes packet into p reusing capacity of p.Entries,
+// nothing is allocated on success
+func UnmarshalInto(p *Packet, b []byte, max int) error {
+	if len(b) < HeaderSize {
+		return parseErr(0, ErrShort)
+	}
+	if (len(b)-HeaderSize)%EntrySize != 0 {
+		return parseErr(len(b)-(len(b)-HeaderSize)%EntrySize, ErrLength)
+	}
+
+	p.Header = Header{
+		Command: b[0],
+		Version: b[1],
+		Zero:    binary.BigEndian.Uint16(b[2:]),
+	}
+	p.Auth = Auth{}
+	p.Entries = p.Entries[:0]
+
+	switch p.Command {
+	case Request, Response:
+	default:
+		return parseErr(0, ErrCommand)
+	}
+
+	switch p.Version {
+	case 1:
+		if p.Zero != 0 {
+			return parseErr(2, ErrMustBeZero)
+		}
+	case 2:
+	default:
+		return parseErr(1, ErrVersion)
+	}
+
+	start, end := HeaderSize, len(b)
+	if len(b) > HeaderSize && binary.BigEndian.Uint16(b[HeaderSize:]) == AFIAuth {
+		if p.Version == 1 {
+			return parseErr(HeaderSize, ErrAuthType)
+		}
+		e := b[HeaderSize : HeaderSize+EntrySize]
+		switch binary.BigEndian.Uint16(e[2:]) {
+		case AuthPlain:
+			p.Auth.Type = AuthPlain
+			copy(p.Auth.Key[:], e[4:])
+		case AuthHash:
+			packLng := int(binary.BigEndian.Uint16(e[4:]))
+			if e[7] != KeySize && e[7] != EntrySize {
+				return parseErr(HeaderSize+7, ErrAuthLength)
+			}
+			//Trailer is the last entry and starts right after route entries
+			if packLng != len(b)-EntrySize || packLng < HeaderSize+EntrySize {
+				return parseErr(HeaderSize+4, ErrTrailer)
+			}
+			t := b[packLng:]
+			if binary.BigEndian.Uint16(t) != AFIAuth || binary.BigEndian.Uint16(t[2:]) != AuthTrailer {
+				return parseErr(packLng, ErrTrailer)
+			}
+			p.Auth = Auth{
+				Type:    AuthHash,
+				KeyID:   e[6],
+				DataLen: e[7],
+				SQN:     binary.BigEndian.Uint32(e[8:]),
+			}
+			copy(p.Auth.Key[:], t[4:])
+			end = packLng
+		default:
+			return parseErr(HeaderSize+2, ErrAuthType)
+		}
+		start += EntrySize
+	}
+
+	if (end-start)/EntrySize > max {
+		return parseErr(start+max*EntrySize, ErrEntryCount)
+	}
+
+	for off := start; off < end; off += EntrySize {
+		e := RouteEntry{
+			AFI:      binary.BigEndian.Uint16(b[off:]),
+			RouteTag: binary.BigEndian.Uint16(b[off+2:]),
+			Network:  binary.BigEndian.Uint32(b[off+4:]),
+			Mask:     binary.BigEndian.Uint32(b[off+8:]),
+			NextHop:  binary.BigEndian.Uint32(b[off+12:]),
+			Metric:   binary.BigEndian.Uint32(b[off+16:]),
+		}
+		if e.AFI == AFIAuth {
+			return parseErr(off, ErrAuthPosition)
+		}
+		if p.Version == 1 && (e.RouteTag != 0 || e.Mask != 0 || e.NextHop != 0) {
+			return parseErr(off+2, ErrMustBeZero)
+		}
+		p.Entries = append(p.Entries, e)
+	}
+
+	return nil
+}
+
+// Size returns encoded packet length
+func Size(p *Packet) int {
+	switch p.Auth.Type {
+	case AuthPlain:
+		return HeaderSize + (len(p.Entries)+1)*EntrySize
+	case AuthHash:
+		return HeaderSize + (len(p.Entries)+2)*EntrySize
+	}
+	return HeaderSize + len(p.Entries)*EntrySize
+}
+
+// Marshal encodes packet, key is used according to Auth.Type
+func Marshal(p *Packet, key string) ([]byte, error) {
+	return AppendMarshal(make([]byte, 0, Size(p)), p, key)
+}
+
+// AppendMarshal appends encoded packet to dst, nothing is allocated
+// when dst has enough capacity
+func AppendMarshal(dst []byte, p *Packet, key string) ([]byte, error) {
+	if len(key) > KeySize {
+		return dst, ErrKeyLength
+	}
+	if p.Version == 1 && p.Auth.Type != AuthNone {
+		return dst, ErrAuthType
+	}
+
+	start := len(dst)
+	dst = append(dst, p.Command, p.Version)
+	dst = binary.BigEndian.AppendUint16(dst, p.Zero)
+
+	switch p.Auth.Type {
+	case AuthNone:
+	case AuthPlain:
+		dst = appendKey(dst, AuthPlain, key)
+	case AuthHash:
+		dst = binary.BigEndian.AppendUint16(dst, AFIAuth)
+		dst = binary.BigEndian.AppendUint16(dst, AuthHash)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(HeaderSize+EntrySize+len(p.Entries)*EntrySize))
+		dataLen := p.Auth.DataLen
+		if dataLen == 0 {
+			dataLen = EntrySize
+		}
+		if dataLen != KeySize && dataLen != EntrySize {
+			return dst[:start], ErrAuthLength
+		}
+		dst = append(dst, p.Auth.KeyID, dataLen)
+		dst = binary.BigEndian.AppendUint32(dst, p.Auth.SQN)
+		dst = binary.BigEndian.AppendUint64(dst, 0)
+	default:
+		return dst[:start], ErrAuthType
+	}
+
+	for _, e := range p.Entries {
+		dst = binary.BigEndian.AppendUint16(dst, e.AFI)
+		dst = binary.BigEndian.AppendUint16(dst, e.RouteTag)
+		dst = binary.BigEndian.AppendUint32(dst, e.Network)
+		dst = binary.BigEndian.AppendUint32(dst, e.Mask)
+		dst = binary.BigEndian.AppendUint32(dst, e.NextHop)
+		dst = binary.BigEndian.AppendUint32(dst, e.Metric)
+	}
+
+	if p.Auth.Type == AuthHash {
+		dst = appendKey(dst, AuthTrailer, key)
+		hash := md5.Sum(dst[start:])
+		copy(dst[len(dst)-KeySize:], hash[:])
+	}
+
+	return dst, nil
+}
+
+// Verify checks packet authentication against key
+func Verify(p *Packet, key string) error {
+	switch p.Auth.Type {
+	case AuthNone:
+		return nil
+	case AuthPlain:
+		pad := padKey(key)
+		if subtle.ConstantTimeCompare(p.Auth.Key[:], pad[:]) != 1 {
+			return ErrAuth
+		}
+		return nil
+	case AuthHash:
+		b, err := Marshal(p, key)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(p.Auth.Key[:], b[len(b)-KeySize:]) != 1 {
+			return ErrAuth
+		}
+		return nil
+	}
+	return ErrAuthType
+}
+
+// VerifyBytes checks authentication of packet b decoded into p without allocating,
+// digest in b is temporarily replaced by key and restored before return
+func VerifyBytes(p *Packet, b []byte, key string) error {
+	if p.Auth.Type != AuthHash {
+		return Verify(p, key)
+	}
+	if len(key) > KeySize {
+		return ErrKeyLength
+	}
+	if len(b) < HeaderSize+2*EntrySize {
+		return ErrTrailer
+	}
+
+	digest := b[len(b)-KeySize:]
+	pad := padKey(key)
+	copy(digest, pad[:])
+	hash := md5.Sum(b)
+	copy(digest, p.Auth.Key[:])
+
+	if subtle.ConstantTimeCompare(p.Auth.Key[:], hash[:]) != 1 {
+		return ErrAuth
+	}
+	return nil
+}
+
+// Sign sets sequence number and recomputes digest of encoded packet b in place,
+// packets without keyed MD5 authentication are left untouched
+func Sign(b []byte, sqn uint32, key string) error {
+	if len(key) > KeySize {
+		return ErrKeyLength
+	}
+	if len(b) < HeaderSize+EntrySize ||
+		binary.BigEndian.Uint16(b[HeaderSize:]) != AFIAuth ||
+		binary.BigEndian.Uint16(b[HeaderSize+2:]) != AuthHash {
+		return nil
+	}
+	if len(b) < HeaderSize+2*EntrySize {
+		return ErrTrailer
+	}
+
+	binary.BigEndian.PutUint32(b[HeaderSize+8:], sqn)
+	digest := b[len(b)-KeySize:]
+	pad := padKey(key)
+	copy(digest, pad[:])
+	hash := md5.Sum(b)
+	copy(digest, hash[:])
+	return nil
+}
+
+func appendKey(dst []byte, authType uint16, key string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, AFIAuth)
+	dst = binary.BigEndian.AppendUint16(dst, authType)
+	pad := padKey(key)
+	return append(dst, pad[:]...)
+}
+
+func padKey(key string) (arr [KeySize]byte) {
+	copy(arr[:], key)
+	return
+}
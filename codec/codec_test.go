@@ -0,0 +1,218 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testKey = "secret"
+
+func testPacket(authType uint16, entries int) *Packet {
+	p := &Packet{
+		Header: Header{Command: Response, Version: 2},
+		Auth:   Auth{Type: authType, KeyID: 1, SQN: 7},
+	}
+	for i := 0; i < entries; i++ {
+		p.Entries = append(p.Entries, RouteEntry{
+			AFI:     AFIIPv4,
+			Network: 10<<24 | uint32(i)<<8,
+			Mask:    0xffffff00,
+			Metric:  1,
+		})
+	}
+	return p
+}
+
+func mustMarshal(t testing.TB, p *Packet) []byte {
+	b, err := Marshal(p, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// malformed are broken packets with error each one must be rejected with
+func malformed(t testing.TB) []struct {
+	name string
+	b    []byte
+	err  error
+} {
+	plain := mustMarshal(t, testPacket(AuthPlain, 2))
+	hash := mustMarshal(t, testPacket(AuthHash, 2))
+
+	//Auth entry moved after a route entry
+	misplaced := append([]byte(nil), plain...)
+	copy(misplaced[HeaderSize:], plain[HeaderSize+EntrySize:HeaderSize+2*EntrySize])
+	copy(misplaced[HeaderSize+EntrySize:], plain[HeaderSize:HeaderSize+EntrySize])
+
+	badTrailer := append([]byte(nil), hash...)
+	binary.BigEndian.PutUint16(badTrailer[len(badTrailer)-EntrySize+2:], AuthPlain)
+
+	badLength := append([]byte(nil), hash...)
+	binary.BigEndian.PutUint16(badLength[HeaderSize+4:], uint16(len(hash)))
+
+	badAuthLen := append([]byte(nil), hash...)
+	badAuthLen[HeaderSize+7] = 0
+
+	rfc := testPacket(AuthHash, 2)
+	rfc.Auth.DataLen = KeySize
+	badRFCLen := mustMarshal(t, rfc)
+	badRFCLen[HeaderSize+7] = KeySize + 1
+
+	v1Auth := append([]byte(nil), plain...)
+	v1Auth[1] = 1
+
+	return []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", nil, ErrShort},
+		{"truncated header", []byte{Response, 2}, ErrShort},
+		{"odd length", plain[:len(plain)-1], ErrLength},
+		{"truncated entry", plain[:HeaderSize+EntrySize/2], ErrLength},
+		{"bad command", append([]byte{9}, plain[1:]...), ErrCommand},
+		{"bad version", append([]byte{Response, 3}, plain[2:]...), ErrVersion},
+		{"v1 auth", v1Auth, ErrAuthType},
+		{"auth not first", misplaced, ErrAuthPosition},
+		{"trailer type", badTrailer, ErrTrailer},
+		{"trailer missing", hash[:len(hash)-EntrySize], ErrTrailer},
+		{"packet length", badLength, ErrTrailer},
+		{"auth data length", badAuthLen, ErrAuthLength},
+		{"auth data length near rfc", badRFCLen, ErrAuthLength},
+		{"too many entries", mustMarshal(t, testPacket(AuthNone, MaxEntries+1)), ErrEntryCount},
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	for _, tc := range malformed(t) {
+		_, err := Unmarshal(tc.b)
+		var perr *ParseError
+		if !errors.As(err, &perr) || !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, authType := range []uint16{AuthNone, AuthPlain, AuthHash} {
+		p := testPacket(authType, MaxEntries-2)
+		b := mustMarshal(t, p)
+		got, err := Unmarshal(b)
+		if err != nil {
+			t.Fatalf("authType %d: %v", authType, err)
+		}
+		if err := VerifyBytes(got, b, testKey); err != nil {
+			t.Fatalf("authType %d: %v", authType, err)
+		}
+		if err := VerifyBytes(got, b, "other"); authType != AuthNone && err != ErrAuth {
+			t.Fatalf("authType %d: wrong key accepted", authType)
+		}
+		got.Auth.Key = p.Auth.Key
+		if authType == AuthHash {
+			//Unset Auth Data Len is sent as 20
+			p.Auth.DataLen = EntrySize
+		} else {
+			got.Auth.KeyID, got.Auth.SQN = p.Auth.KeyID, p.Auth.SQN
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Fatalf("authType %d: got %+v, want %+v", authType, got, p)
+		}
+	}
+}
+
+// RFC 4822 Auth Data Len of 16 is accepted and kept for verification
+func TestAuthDataLen(t *testing.T) {
+	for _, dataLen := range []uint8{KeySize, EntrySize} {
+		p := testPacket(AuthHash, 3)
+		p.Auth.DataLen = dataLen
+		b := mustMarshal(t, p)
+		if b[HeaderSize+7] != dataLen {
+			t.Fatalf("Auth Data Len %d encoded as %d", dataLen, b[HeaderSize+7])
+		}
+		got, err := Unmarshal(b)
+		if err != nil {
+			t.Fatalf("Auth Data Len %d: %v", dataLen, err)
+		}
+		if got.Auth.DataLen != dataLen {
+			t.Fatalf("Auth Data Len %d decoded as %d", dataLen, got.Auth.DataLen)
+		}
+		if err := VerifyBytes(got, b, testKey); err != nil {
+			t.Fatalf("Auth Data Len %d: %v", dataLen, err)
+		}
+		if err := Verify(got, testKey); err != nil {
+			t.Fatalf("Auth Data Len %d: %v", dataLen, err)
+		}
+	}
+	p := testPacket(AuthHash, 1)
+	p.Auth.DataLen = 7
+	if _, err := Marshal(p, testKey); err != ErrAuthLength {
+		t.Fatalf("Auth Data Len 7 encoded, err %v", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	p := testPacket(AuthHash, 3)
+	b := mustMarshal(t, p)
+	p.Auth.SQN = 8
+	want := mustMarshal(t, p)
+	if err := Sign(b, 8, testKey); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("signed packet differs from marshaled one")
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, authType := range []uint16{AuthNone, AuthPlain, AuthHash} {
+		for _, n := range []int{0, 1, MaxEntries - 2} {
+			f.Add(mustMarshal(f, testPacket(authType, n)))
+		}
+	}
+	rfc := testPacket(AuthHash, 2)
+	rfc.Auth.DataLen = KeySize
+	f.Add(mustMarshal(f, rfc))
+	for _, tc := range malformed(f) {
+		f.Add(tc.b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		in := append([]byte(nil), b...)
+		p, err := Unmarshal(b)
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("error %v is not ParseError", err)
+			}
+			return
+		}
+		//Verification must not panic and must leave input intact
+		VerifyBytes(p, b, testKey)
+		if !bytes.Equal(in, b) {
+			t.Fatalf("VerifyBytes modified input")
+		}
+
+		key := string(bytes.TrimRight(p.Auth.Key[:], "\x00"))
+		out, err := Marshal(p, key)
+		if err != nil {
+			t.Fatalf("decoded packet does not encode: %v", err)
+		}
+		if p.Auth.Type != AuthHash && !bytes.Equal(out, b) {
+			t.Fatalf("re-encoded packet differs:\n%x\n%x", out, b)
+		}
+		q, err := Unmarshal(out)
+		if err != nil {
+			t.Fatalf("encoded packet does not decode: %v", err)
+		}
+		if p.Auth.Type == AuthHash {
+			q.Auth.Key = p.Auth.Key
+		}
+		if !reflect.DeepEqual(p, q) {
+			t.Fatalf("round trip mismatch:\n%+v\n%+v", p, q)
+		}
+	})
+}
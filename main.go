@@ -1,159 +1,86 @@
 package main
 
 import (
-	"encoding/binary"
 	"flag"
-	"net"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
-)
-
-type system struct {
-	config  *config
-	socket  *socket
-	local   *local
-	signal  *sign
-	logger  logger
-	cfgPath string
-}
 
-type sign struct {
-	resetAdj    chan struct{}
-	resetNbr    chan struct{}
-	stopSched   chan struct{}
-	stopReceive chan struct{}
-	getAdj      chan struct{}
-	getNbr      chan struct{}
-}
-
-var sys = system{}
+	"github.com/k-danil/ripv2-go/rip"
+)
 
-func init() {
+func main() {
+	// defer profile.Start(profile.MemProfile).Stop()
 	const (
 		defaultCfgPath = "settings.toml"
 	)
-	flag.StringVar(&sys.cfgPath, "f", defaultCfgPath, "config file")
+	var (
+		cfgPath string
+		check   bool
+		dump    bool
+	)
+	flag.StringVar(&cfgPath, "f", defaultCfgPath, "config file")
+	flag.BoolVar(&check, "check", false, "validate config file and exit")
+	flag.BoolVar(&dump, "print", false, "print effective config with keys redacted and exit")
 	flag.Parse()
 
-	sys.logger = logProcess()
-	sys.signal = signalProcess()
-}
-
-func main() {
-	// defer profile.Start(profile.MemProfile).Stop()
-	var err error
-
-	sys.logger.send(info, "starting main")
-
-	clrRoutes()
-
-	if sys.config, err = readConfig(); err != nil {
-		sys.logger.send(fatal, err)
+	conf, err := rip.ReadConfig(cfgPath)
+	if check || dump {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if dump {
+			if err := conf.WriteEffective(os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		fmt.Println(cfgPath + ": ok")
+		return
 	}
-
-	if sys.socket, err = socketOpen(); err != nil {
-		sys.logger.send(fatal, err)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	adj := initAdjTable()
-	nbr := initNbrTable()
+	router, err := rip.New(conf, rip.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// go tableSubscr()
+	signChan := make(chan os.Signal, 1)
+	signal.Notify(signChan, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, os.Interrupt, syscall.SIGTERM)
 
-	if err = sys.socket.joinMcast(); err != nil {
-		sys.logger.send(erro, err)
+	if err := router.Start(); err != nil {
+		log.Fatal(err)
 	}
 
-	defer clrRoutes()
-	defer sys.socket.close()
-	defer sys.logger.send(info, "closing main")
-
-Loop:
 	for {
 		select {
-		case <-sys.signal.stopReceive:
-			break Loop
-		default:
-			b := make([]byte, (sys.config.Global.EntryCount*entrySize + headerSize))
-
-			s, cm, _, err := sys.socket.connect.ReadFrom(b)
-			if err, ok := err.(net.Error); ok && !err.Timeout() {
-				sys.logger.send(fatal, err)
-			} else if err, ok := err.(net.Error); ok && err.Timeout() {
-				break
-			}
-
-			go func() {
-				src := binary.BigEndian.Uint32(cm.Src)
-				packet, err := readPacket(b[:s], cm.IfIndex, src)
-				if err != nil {
-					//Drop weird sourced packet
-					return
-				}
-
-				pdu := packet.parse()
-				if sys.config.Global.Log == debug {
-					sys.logger.send(debug, pdu)
-				}
-
-				if _, ok := sys.config.Neighbors[src]; ok {
-					err = pdu.validate(sys.config.Neighbors[src].KeyChain)
-				} else if _, ok := sys.config.Interfaces[cm.IfIndex]; ok {
-					err = pdu.validate(sys.config.Interfaces[cm.IfIndex].KeyChain)
-				}
-
-				if err != nil {
-					sys.logger.send(warn, err)
-				} else {
-					nbr.update(pdu.serviceFields.ip, pdu.serviceFields.ifi)
-					adj.procIncom(pdu)
-				}
-			}()
-		}
-	}
-}
-
-func signalProcess() *sign {
-	signChan := make(chan os.Signal)
-	signal.Notify(signChan)
-
-	sign := &sign{}
-	sign.getAdj = make(chan struct{})
-	sign.getNbr = make(chan struct{})
-	sign.resetAdj = make(chan struct{})
-	sign.resetNbr = make(chan struct{})
-	sign.stopReceive = make(chan struct{})
-	sign.stopSched = make(chan struct{})
-
-	go func() {
-		for s := range signChan {
+		case s := <-signChan:
 			switch s {
 			case syscall.SIGHUP:
-				if config, err := readConfig(); err != nil {
-					sys.logger.send(erro, err)
-				} else {
-					sys.config = config
+				if conf, err := rip.ReadConfig(cfgPath); err != nil {
+					log.Print(err)
+				} else if err := router.Reload(conf); err != nil {
+					log.Print(err)
 				}
-				if err := sys.socket.leaveMcast(); err != nil {
-					sys.logger.send(erro, err)
-				}
-				if err := sys.socket.joinMcast(); err != nil {
-					sys.logger.send(erro, err)
-				}
-				sign.resetAdj <- struct{}{}
-			case os.Interrupt:
-				sign.stopSched <- struct{}{}
-				sign.stopReceive <- struct{}{}
-				sys.socket.timeout(1)
+			case os.Interrupt, syscall.SIGTERM:
+				router.Stop()
 				return
 			case syscall.SIGUSR1:
-				sign.getAdj <- struct{}{}
+				router.DumpAdj()
 			case syscall.SIGUSR2:
-				sign.getNbr <- struct{}{}
+				router.DumpNbr()
+			}
+		case <-router.Done():
+			if err := router.Err(); err != nil {
+				log.Fatal(err)
 			}
+			return
 		}
-	}()
-
-	return sign
+	}
 }
@@ -0,0 +1,124 @@
+package rip
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+)
+
+// addrTable caches local IPv4 addresses, kept fresh by netlink address events
+type addrTable struct {
+	r     *Router
+	entry map[int][]net.IPNet
+	local map[uint32]int
+	mux   sync.RWMutex
+}
+
+func (r *Router) initAddrTable() (*addrTable, error) {
+	t := &addrTable{r: r}
+	if err := t.sync(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// sync replaces cache with a full dump
+func (t *addrTable) sync() error {
+	iplist, err := netlink.AddrList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return err
+	}
+
+	entry := make(map[int][]net.IPNet)
+	local := make(map[uint32]int, len(iplist))
+	for _, ipAddr := range iplist {
+		ip := ipAddr.IP.To4()
+		if ip == nil {
+			continue
+		}
+		entry[ipAddr.LinkIndex] = append(entry[ipAddr.LinkIndex], net.IPNet{IP: ip, Mask: ipAddr.Mask})
+		local[binary.BigEndian.Uint32(ip)] = ipAddr.LinkIndex
+	}
+
+	t.mux.Lock()
+	t.entry, t.local = entry, local
+	t.mux.Unlock()
+	return nil
+}
+
+func (t *addrTable) update(msg netlink.AddrUpdate) {
+	ip := msg.LinkAddress.IP.To4()
+	if ip == nil {
+		return
+	}
+	key := binary.BigEndian.Uint32(ip)
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	list := t.entry[msg.LinkIndex]
+	for i, ipNet := range list {
+		if ipNet.IP.Equal(ip) {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	if msg.NewAddr {
+		list = append(list, net.IPNet{IP: ip, Mask: msg.LinkAddress.Mask})
+		t.local[key] = msg.LinkIndex
+	} else {
+		delete(t.local, key)
+	}
+
+	if len(list) == 0 {
+		delete(t.entry, msg.LinkIndex)
+	} else {
+		t.entry[msg.LinkIndex] = list
+	}
+}
+
+// drop forgets addresses of a removed interface
+func (t *addrTable) drop(ifi int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, ipNet := range t.entry[ifi] {
+		delete(t.local, binary.BigEndian.Uint32(ipNet.IP))
+	}
+	delete(t.entry, ifi)
+}
+
+func (t *addrTable) isLocal(ip uint32) bool {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	_, ok := t.local[ip]
+	return ok
+}
+
+// list returns addresses of ifi or of all interfaces when ifi is zero
+func (t *addrTable) list(ifi int) []net.IPNet {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if ifi != 0 {
+		return append([]net.IPNet(nil), t.entry[ifi]...)
+	}
+	l := make([]net.IPNet, 0, len(t.local))
+	for _, ipNets := range t.entry {
+		l = append(l, ipNets...)
+	}
+	return l
+}
+
+// onLink reports whether ip is directly reachable via ifi
+func (t *addrTable) onLink(ifi int, ip uint32) bool {
+	addr := uintToIP(ip)
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	for _, ipNet := range t.entry[ifi] {
+		if ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,408 @@
+package rip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k-danil/ripv2-go/codec"
+)
+
+const (
+	defaultEntryCount     = 25
+	defaultUpdateTimer    = 30 * time.Second
+	defaultTimeoutTimer   = 180 * time.Second
+	defaultGarbageTimer   = 120 * time.Second
+	defaultReconcileTimer = 60 * time.Second
+	defaultLocalMetric    = 10
+	defaultQueueSize      = 128
+)
+
+// Config is daemon configuration as read from TOML file
+type Config struct {
+	Include    []string             `toml:"include,omitempty"`
+	Network    []string             `toml:"network"`
+	Global     Global               `toml:"global"`
+	Timers     Timers               `toml:"timers"`
+	Interfaces map[string]Interface `toml:"interfaces"`
+	Neighbors  map[string]Neighbor  `toml:"neighbors"`
+
+	nbrs     map[uint32]Neighbor
+	patterns []string
+	networks []*net.IPNet
+	//Keys of Interfaces and Neighbors are resolved
+	keysLoaded bool
+}
+
+// Global holds instance wide parameters
+type Global struct {
+	Metric     int    `toml:"metric"`
+	EntryCount int    `toml:"entryCount"`
+	Log        uint8  `toml:"log"`
+	FIB        string `toml:"fib"`
+	FIBFile    string `toml:"fibFile,omitempty"`
+	Workers    int    `toml:"workers"`
+	QueueSize  int    `toml:"queueSize"`
+	//Milliseconds between packets of one multi-packet update
+	PacketDelay int `toml:"packetDelay"`
+}
+
+// Timers holds protocol timers, zero interface timers inherit global ones
+type Timers struct {
+	UpdateTimer    Duration `toml:"updateTimer,omitzero"`
+	TimeoutTimer   Duration `toml:"timeoutTimer,omitzero"`
+	GarbageTimer   Duration `toml:"garbageTimer,omitzero"`
+	ReconcileTimer Duration `toml:"reconcileTimer,omitzero"`
+}
+
+// Duration is a timer value, TOML integer is seconds and string is
+// Go duration like "500ms"
+type Duration time.Duration
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (d *Duration) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		*d = Duration(time.Duration(v) * time.Second)
+	case string:
+		p, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(p)
+	default:
+		return fmt.Errorf("timer must be seconds or duration string, got %v", v)
+	}
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText writes duration string readable by UnmarshalTOML
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Interface holds per interface parameters, map key is name or glob pattern
+type Interface struct {
+	Passive  bool     `toml:"passive,omitempty"`
+	KeyChain KeyChain `toml:"keychain"`
+	Timers   Timers   `toml:"timers"`
+}
+
+// Neighbor holds static neighbor parameters, map key is neighbor IP
+type Neighbor struct {
+	KeyChain KeyChain `toml:"keychain"`
+}
+
+// KeyChain holds authentication parameters, key is given inline or
+// read from file, environment variable or command output on each load
+type KeyChain struct {
+	AuthType       uint16 `toml:"authType"`
+	AuthKey        string `toml:"authKey,omitempty"`
+	AuthKeyFile    string `toml:"authKeyFile,omitempty"`
+	AuthKeyEnv     string `toml:"authKeyEnv,omitempty"`
+	AuthKeyCommand string `toml:"authKeyCommand,omitempty"`
+}
+
+// ReadConfig decodes TOML config file merging fragments matched by its include
+// globs, unknown keys, conflicting definitions and invalid values are all
+// reported in returned error
+func ReadConfig(path string) (*Config, error) {
+	var conf Config
+	m := merger{conf: &conf, owner: make(map[string]string)}
+	if err := m.decode(path); err != nil {
+		return nil, err
+	}
+
+	includes := conf.Include
+	conf.Include = nil
+	files, err := expandIncludes(path, includes)
+	if err != nil {
+		m.errs = append(m.errs, err)
+	}
+	for _, file := range files {
+		if err := m.decode(file); err != nil {
+			m.errs = append(m.errs, err)
+		}
+	}
+
+	errs := m.errs
+	invalid := conf.loadKeys()
+	conf.keysLoaded = true
+	invalid = append(invalid, unjoin(conf.Check())...)
+	for _, err := range invalid {
+		errs = append(errs, m.attribute(path, err))
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &conf, nil
+}
+
+// Check validates config without applying it
+func (c *Config) Check() error {
+	_, err := c.compile()
+	return err
+}
+
+func (c *Config) compile() (*Config, error) {
+	conf := *c
+	var errs []error
+	conf.nbrs = make(map[uint32]Neighbor, len(c.Neighbors))
+	conf.Interfaces = make(map[string]Interface, len(c.Interfaces))
+	for ifn, ifc := range c.Interfaces {
+		conf.Interfaces[ifn] = ifc
+	}
+	conf.Neighbors = make(map[string]Neighbor, len(c.Neighbors))
+	for ipn, nbr := range c.Neighbors {
+		conf.Neighbors[ipn] = nbr
+	}
+	if !conf.keysLoaded {
+		errs = append(errs, conf.loadKeys()...)
+		conf.keysLoaded = true
+	}
+	conf.patterns = nil
+	conf.networks = nil
+
+	for ifn := range conf.Interfaces {
+		if !strings.ContainsAny(ifn, "*?[") {
+			continue
+		}
+		if _, err := path.Match(ifn, ""); err != nil {
+			errs = append(errs, fmt.Errorf("interfaces.%q: malformed pattern", ifn))
+			continue
+		}
+		conf.patterns = append(conf.patterns, ifn)
+	}
+	//More literal characters means more specific pattern
+	sort.Slice(conf.patterns, func(i, j int) bool {
+		li, lj := literalLen(conf.patterns[i]), literalLen(conf.patterns[j])
+		if li != lj {
+			return li > lj
+		}
+		return conf.patterns[i] < conf.patterns[j]
+	})
+
+	for ipn, param := range conf.Neighbors {
+		ip := net.ParseIP(ipn).To4()
+		if !ip.IsGlobalUnicast() {
+			errs = append(errs, fmt.Errorf("neighbors.%q: not an IPv4 unicast address", ipn))
+			continue
+		}
+		conf.nbrs[binary.BigEndian.Uint32(ip)] = param
+	}
+
+	for i, netn := range conf.Network {
+		_, netid, err := net.ParseCIDR(netn)
+		if err != nil || netid.IP.To4() == nil {
+			errs = append(errs, fmt.Errorf("network[%d]: %q is not an IPv4 prefix", i, netn))
+			continue
+		}
+		conf.networks = append(conf.networks, netid)
+	}
+
+	errs = append(errs, conf.validate()...)
+
+	return &conf, errors.Join(errs...)
+}
+
+// timers returns global timers overridden by non-zero ones of ifc
+func (c *Config) timers(ifc Interface) Timers {
+	t := c.Timers
+	if ifc.Timers.UpdateTimer != 0 {
+		t.UpdateTimer = ifc.Timers.UpdateTimer
+	}
+	if ifc.Timers.TimeoutTimer != 0 {
+		t.TimeoutTimer = ifc.Timers.TimeoutTimer
+	}
+	if ifc.Timers.GarbageTimer != 0 {
+		t.GarbageTimer = ifc.Timers.GarbageTimer
+	}
+	return t
+}
+
+// poll is scheduler resolution, fine enough for the fastest update timer
+func (c *Config) poll() time.Duration {
+	min := time.Duration(c.Timers.UpdateTimer)
+	for _, ifc := range c.Interfaces {
+		if u := time.Duration(ifc.Timers.UpdateTimer); u != 0 && u < min {
+			min = u
+		}
+	}
+	poll := min / 6
+	switch {
+	case poll < 10*time.Millisecond:
+		poll = 10 * time.Millisecond
+	case poll > 5*time.Second:
+		poll = 5 * time.Second
+	}
+	return poll
+}
+
+func (c *Config) ifcByName(name string) (Interface, bool) {
+	if param, ok := c.Interfaces[name]; ok {
+		return param, true
+	}
+	for _, pattern := range c.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return c.Interfaces[pattern], true
+		}
+	}
+	return Interface{}, false
+}
+
+func (c *Config) inNetworks(ip net.IP) bool {
+	for _, netid := range c.networks {
+		if netid.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func literalLen(pattern string) int {
+	var l int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				i += end
+			}
+		case '\\':
+			i++
+			l++
+		default:
+			l++
+		}
+	}
+	return l
+}
+
+// validate fills unset values with defaults and reports out of range ones
+func (c *Config) validate() (errs []error) {
+	g := &c.Global
+	if g.Workers == 0 {
+		g.Workers = runtime.NumCPU()
+	}
+	if g.QueueSize == 0 {
+		g.QueueSize = defaultQueueSize
+	}
+	if g.Metric == 0 {
+		g.Metric = defaultLocalMetric
+	}
+	if g.EntryCount == 0 {
+		g.EntryCount = defaultEntryCount
+	}
+
+	rangeErr := func(key string, v, min, max interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %v is out of range %v-%v", key, v, min, max))
+	}
+	if g.Metric < 1 || g.Metric > 255 {
+		rangeErr("global.metric", g.Metric, 1, 255)
+	}
+	if g.EntryCount < 25 || g.EntryCount > maxEntryCount {
+		rangeErr("global.entryCount", g.EntryCount, 25, maxEntryCount)
+	}
+	if g.Log > LogDebug {
+		rangeErr("global.log", g.Log, LogUser, LogDebug)
+	}
+	if g.Workers < 0 {
+		rangeErr("global.workers", g.Workers, 0, "CPU count")
+	}
+	if g.QueueSize < 0 {
+		rangeErr("global.queueSize", g.QueueSize, 0, "any")
+	}
+	if g.PacketDelay < 0 || g.PacketDelay > 1000 {
+		rangeErr("global.packetDelay", g.PacketDelay, 0, 1000)
+	}
+	switch g.FIB {
+	case "", fibNetlink, fibDryRun:
+	case fibFile:
+		if g.FIBFile == "" {
+			errs = append(errs, errors.New("global.fibFile: required by file FIB backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("global.fib: unknown backend %q", g.FIB))
+	}
+
+	if c.Timers.ReconcileTimer == 0 {
+		c.Timers.ReconcileTimer = Duration(defaultReconcileTimer)
+	}
+	if c.Timers.ReconcileTimer < Duration(time.Second) || c.Timers.ReconcileTimer > Duration(time.Hour) {
+		rangeErr("timers.reconcileTimer", c.Timers.ReconcileTimer, time.Second, time.Hour)
+	}
+	errs = append(errs, c.Timers.validate("timers", true)...)
+	//Routes must outlive the interval they are refreshed at
+	order := func(key string, t Timers) {
+		if t.TimeoutTimer <= t.UpdateTimer {
+			errs = append(errs, fmt.Errorf("%s.timeoutTimer: %v must be longer than updateTimer %v", key, t.TimeoutTimer, t.UpdateTimer))
+		}
+	}
+	order("timers", c.Timers)
+
+	for name, ifc := range c.Interfaces {
+		key := fmt.Sprintf("interfaces.%q", name)
+		if ifc.Timers.ReconcileTimer != 0 {
+			errs = append(errs, errors.New(key+".timers.reconcileTimer: is global only"))
+		}
+		errs = append(errs, ifc.Timers.validate(key+".timers", false)...)
+		if ifc.Timers.UpdateTimer != 0 || ifc.Timers.TimeoutTimer != 0 {
+			order(key+".timers", c.timers(ifc))
+		}
+		errs = append(errs, ifc.KeyChain.validate(key+".keychain")...)
+		c.Interfaces[name] = ifc
+	}
+	for ipn, nbr := range c.Neighbors {
+		errs = append(errs, nbr.KeyChain.validate(fmt.Sprintf("neighbors.%q.keychain", ipn))...)
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// validate reports out of range timers, unset global ones take defaults
+// and unset interface ones keep inheriting
+func (t *Timers) validate(key string, global bool) (errs []error) {
+	check := func(d *Duration, def, min, max time.Duration, name string) {
+		if *d == 0 {
+			if global {
+				*d = Duration(def)
+			}
+			return
+		}
+		if time.Duration(*d) < min || time.Duration(*d) > max {
+			errs = append(errs, fmt.Errorf("%s.%s: %v is out of range %v-%v", key, name, *d, min, max))
+		}
+	}
+	check(&t.UpdateTimer, defaultUpdateTimer, 100*time.Millisecond, 60*time.Second, "updateTimer")
+	check(&t.TimeoutTimer, defaultTimeoutTimer, 300*time.Millisecond, 360*time.Second, "timeoutTimer")
+	check(&t.GarbageTimer, defaultGarbageTimer, 200*time.Millisecond, 180*time.Second, "garbageTimer")
+	return
+}
+
+func (k *KeyChain) validate(key string) (errs []error) {
+	switch k.AuthType {
+	case authNon, authPlain, authHash:
+	default:
+		errs = append(errs, fmt.Errorf("%s.authType: %d is not one of 0, 2, 3", key, k.AuthType))
+	}
+	if len(k.AuthKey) > codec.KeySize {
+		errs = append(errs, fmt.Errorf("%s.authKey: longer than %d bytes", key, codec.KeySize))
+	}
+	//Unresolved key source is reported by loadKeys
+	unresolved := k.AuthKeyFile != "" || k.AuthKeyEnv != "" || k.AuthKeyCommand != ""
+	if k.AuthType != authNon && k.AuthKey == "" && !unresolved {
+		errs = append(errs, fmt.Errorf("%s.authKey: required by authType %d", key, k.AuthType))
+	}
+	return
+}
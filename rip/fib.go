@@ -0,0 +1,351 @@
+package rip
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	fibNetlink = "netlink"
+	fibDryRun  = "dryrun"
+	fibFile    = "file"
+)
+
+const ripProto = 10
+
+// Route is a FIB entry owned by RIP
+type Route struct {
+	Dst    *net.IPNet
+	Gw     net.IP
+	Metric int
+}
+
+// FIB installs routes chosen by Router
+type FIB interface {
+	Add(route Route) error
+	Replace(route Route) error
+	Remove(route Route) error
+	List() ([]Route, error)
+	Flush() error
+}
+
+// FIBWatcher is implemented by backends able to report external route changes
+type FIBWatcher interface {
+	Watch(ch chan<- struct{}, done <-chan struct{}) error
+}
+
+func (r *Router) newFib(g *Global) (FIB, error) {
+	switch g.FIB {
+	case "", fibNetlink:
+		return NewNetlinkFIB(), nil
+	case fibDryRun:
+		return NewDryRunFIB(r.log.out), nil
+	case fibFile:
+		if g.FIBFile == "" {
+			return nil, errors.New("file FIB backend needs fibFile path")
+		}
+		return NewFileFIB(g.FIBFile), nil
+	}
+	return nil, errors.New("unknown FIB backend " + g.FIB)
+}
+
+func routeKey(route Route) ipNet {
+	if route.Dst == nil {
+		return ipNet{}
+	}
+	return ipNet{
+		IP:   binary.BigEndian.Uint32(route.Dst.IP.To4()),
+		Mask: binary.BigEndian.Uint32(route.Dst.Mask),
+	}
+}
+
+type nlFib struct{}
+
+// NewNetlinkFIB returns FIB writing to kernel main table
+func NewNetlinkFIB() FIB {
+	return &nlFib{}
+}
+
+func (f *nlFib) route(route Route) *netlink.Route {
+	return &netlink.Route{
+		Dst:      route.Dst,
+		Protocol: ripProto,
+		Priority: route.Metric,
+		Gw:       route.Gw,
+	}
+}
+
+func (f *nlFib) Add(route Route) error {
+	return netlink.RouteAdd(f.route(route))
+}
+
+func (f *nlFib) Replace(route Route) error {
+	return netlink.RouteReplace(f.route(route))
+}
+
+func (f *nlFib) Remove(route Route) error {
+	return netlink.RouteDel(f.route(route))
+}
+
+func (f *nlFib) List() ([]Route, error) {
+	filter := &netlink.Route{
+		Protocol: ripProto,
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		l = append(l, Route{Dst: route.Dst, Gw: route.Gw, Metric: route.Priority})
+	}
+	return l, nil
+}
+
+func (f *nlFib) Flush() error {
+	filter := &netlink.Route{
+		Protocol: ripProto,
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if err := netlink.RouteDel(&route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *nlFib) Watch(ch chan<- struct{}, done <-chan struct{}) error {
+	updates := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribe(updates, done); err != nil {
+		return err
+	}
+	go func() {
+		for range updates {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
+
+// mapFib keeps desired FIB in memory
+type mapFib struct {
+	routes map[ipNet]Route
+	mux    sync.Mutex
+}
+
+func (f *mapFib) add(route Route) error {
+	if _, ok := f.routes[routeKey(route)]; ok {
+		return errors.New("route " + routeKey(route).String() + " exists")
+	}
+	f.routes[routeKey(route)] = route
+	return nil
+}
+
+func (f *mapFib) remove(route Route) error {
+	if _, ok := f.routes[routeKey(route)]; !ok {
+		return errors.New("route " + routeKey(route).String() + " not found")
+	}
+	delete(f.routes, routeKey(route))
+	return nil
+}
+
+func (f *mapFib) List() ([]Route, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	l := make([]Route, 0, len(f.routes))
+	for _, route := range f.routes {
+		l = append(l, route)
+	}
+	return l, nil
+}
+
+// dryFib only logs route changes
+type dryFib struct {
+	mapFib
+	log Logger
+}
+
+// NewDryRunFIB returns FIB logging changes without touching the kernel
+func NewDryRunFIB(log Logger) FIB {
+	return &dryFib{mapFib: mapFib{routes: make(map[ipNet]Route)}, log: log}
+}
+
+func (f *dryFib) Add(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err := f.add(route); err != nil {
+		return err
+	}
+	f.log.Log(info, fmt.Sprintf("dry-run: add %v via %v", route.Dst, route.Gw))
+	return nil
+}
+
+func (f *dryFib) Replace(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.routes[routeKey(route)] = route
+	f.log.Log(info, fmt.Sprintf("dry-run: replace %v via %v", route.Dst, route.Gw))
+	return nil
+}
+
+func (f *dryFib) Remove(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err := f.remove(route); err != nil {
+		return err
+	}
+	f.log.Log(info, fmt.Sprintf("dry-run: remove %v", route.Dst))
+	return nil
+}
+
+func (f *dryFib) Flush() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for netid := range f.routes {
+		delete(f.routes, netid)
+	}
+	f.log.Log(info, "dry-run: flush")
+	return nil
+}
+
+// fileFib keeps JSON view of desired FIB in a file, rewriting whole file
+// per change would make learning a table quadratic, so changes are
+// collected for fileFibDelay and written at once by a timer
+type fileFib struct {
+	mapFib
+	path    string
+	pending bool
+	err     error
+	//Serializes writes so an older snapshot never replaces a newer one
+	wmux sync.Mutex
+}
+
+const fileFibDelay = 100 * time.Millisecond
+
+type fileRoute struct {
+	Prefix  string `json:"prefix"`
+	NextHop string `json:"nextHop"`
+	Metric  int    `json:"metric"`
+}
+
+// NewFileFIB returns FIB keeping JSON view of desired routes in path,
+// failure of a delayed write is returned by the next change
+func NewFileFIB(path string) FIB {
+	return &fileFib{mapFib: mapFib{routes: make(map[ipNet]Route)}, path: path}
+}
+
+func (f *fileFib) Add(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err := f.add(route); err != nil {
+		return err
+	}
+	return f.dirty()
+}
+
+func (f *fileFib) Replace(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.routes[routeKey(route)] = route
+	return f.dirty()
+}
+
+func (f *fileFib) Remove(route Route) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err := f.remove(route); err != nil {
+		return err
+	}
+	return f.dirty()
+}
+
+// Flush writes empty file right away, it is the last call on stop
+func (f *fileFib) Flush() error {
+	f.mux.Lock()
+	for netid := range f.routes {
+		delete(f.routes, netid)
+	}
+	f.mux.Unlock()
+	return f.sync()
+}
+
+// dirty schedules write and reports failure of the previous one, caller holds f.mux
+func (f *fileFib) dirty() error {
+	if !f.pending {
+		f.pending = true
+		time.AfterFunc(fileFibDelay, func() {
+			if err := f.sync(); err != nil {
+				f.mux.Lock()
+				f.err = err
+				f.mux.Unlock()
+			}
+		})
+	}
+	err := f.err
+	f.err = nil
+	return err
+}
+
+// sync writes current routes, file work is done without holding f.mux
+func (f *fileFib) sync() error {
+	f.wmux.Lock()
+	defer f.wmux.Unlock()
+
+	f.mux.Lock()
+	routes := make([]fileRoute, 0, len(f.routes))
+	for netid, route := range f.routes {
+		routes = append(routes, fileRoute{
+			Prefix:  netid.String(),
+			NextHop: route.Gw.String(),
+			Metric:  route.Metric,
+		})
+	}
+	f.pending = false
+	f.mux.Unlock()
+
+	return f.write(routes)
+}
+
+func (f *fileFib) write(routes []fileRoute) error {
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Prefix < routes[j].Prefix })
+
+	b, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	//Readers must never see partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
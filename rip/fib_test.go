@@ -0,0 +1,56 @@
+package rip
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFileFib(t *testing.T, path string) []fileRoute {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routes []fileRoute
+	if err := json.Unmarshal(b, &routes); err != nil {
+		t.Fatal(err)
+	}
+	return routes
+}
+
+func TestFileFib(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fib.json")
+	f := NewFileFIB(path)
+
+	const n = 5000
+	start := time.Now()
+	for _, netid := range benchNets(n) {
+		f.Replace(Route{Dst: &net.IPNet{IP: uintToIP(netid.IP), Mask: net.IPMask(uintToIP(netid.Mask))}, Gw: net.IPv4(192, 168, 1, 2), Metric: 10})
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("%d changes took %v", n, d)
+	}
+
+	routes, _ := f.List()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil && len(readFileFib(t, path)) == len(routes) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delayed write did not happen")
+		}
+		time.Sleep(fileFibDelay)
+	}
+
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if routes := readFileFib(t, path); len(routes) != 0 {
+		t.Fatalf("%d routes left after flush", len(routes))
+	}
+}
@@ -0,0 +1,148 @@
+package rip
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// merger decodes main config and its fragments into one Config,
+// every global or timer value and every interface or neighbor
+// may be defined by one file only
+type merger struct {
+	conf     *Config
+	owner    map[string]string
+	fragment bool
+	errs     []error
+}
+
+func (m *merger) decode(file string) error {
+	network := m.conf.Network
+	m.conf.Network = nil
+
+	md, err := toml.DecodeFile(file, m.conf)
+	m.conf.Network = append(network, m.conf.Network...)
+	if err != nil {
+		return err
+	}
+
+	if m.fragment && len(m.conf.Include) != 0 {
+		m.errs = append(m.errs, fmt.Errorf("%s: include: allowed in main config only", file))
+		m.conf.Include = nil
+	}
+	m.fragment = true
+
+	for _, key := range md.Undecoded() {
+		m.errs = append(m.errs, fmt.Errorf("%s: %s: unknown key", file, key))
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range md.Keys() {
+		id, ok := definition(key)
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if prev, ok := m.owner[id]; ok {
+			m.errs = append(m.errs, fmt.Errorf("%s: %s: already defined in %s", file, id, prev))
+			continue
+		}
+		m.owner[id] = file
+	}
+	return nil
+}
+
+// definition names what key defines: a global or timer value,
+// or a whole interface or neighbor
+func definition(key toml.Key) (string, bool) {
+	if len(key) < 2 {
+		return "", false
+	}
+	switch strings.ToLower(key[0]) {
+	case "global", "timers":
+		return strings.ToLower(key[0] + "." + key[1]), true
+	case "interfaces", "neighbors":
+		return fmt.Sprintf("%s.%q", strings.ToLower(key[0]), key[1]), true
+	}
+	return "", false
+}
+
+// attribute prefixes err with the file defining the value it is about,
+// errors not tied to one definition go to main config
+func (m *merger) attribute(main string, err error) error {
+	msg := err.Error()
+	file, best := main, 0
+	for id, f := range m.owner {
+		if len(id) <= best || len(msg) <= len(id) || (msg[len(id)] != '.' && msg[len(id)] != ':') {
+			continue
+		}
+		//Global and timer keys are matched case insensitively by decoder
+		prefix := msg[:len(id)]
+		if prefix == id || (!strings.HasPrefix(id, "interfaces.") && !strings.HasPrefix(id, "neighbors.") && strings.EqualFold(prefix, id)) {
+			file, best = f, len(id)
+		}
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+// unjoin splits error made by errors.Join
+func unjoin(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	if err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// expandIncludes resolves globs relative to main config directory,
+// matches of each glob are taken in lexical order
+func expandIncludes(path string, globs []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, glob := range globs {
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(filepath.Dir(path), glob)
+		}
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return files, fmt.Errorf("%s: include: %q: %w", path, glob, err)
+		}
+		sort.Strings(matches)
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// WriteEffective writes config with defaults applied and keys redacted
+func (c *Config) WriteEffective(w io.Writer) error {
+	conf, err := c.compile()
+	if err != nil {
+		return err
+	}
+
+	redact := func(k *KeyChain) {
+		if k.AuthKey != "" {
+			k.AuthKey = "<redacted>"
+		}
+	}
+	for name, ifc := range conf.Interfaces {
+		redact(&ifc.KeyChain)
+		conf.Interfaces[name] = ifc
+	}
+	for ipn, nbr := range conf.Neighbors {
+		redact(&nbr.KeyChain)
+		conf.Neighbors[ipn] = nbr
+	}
+	return toml.NewEncoder(w).Encode(conf)
+}
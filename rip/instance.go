@@ -0,0 +1,257 @@
+package rip
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is time source for protocol timers, intervals are measured
+// with Time.Sub so times carrying monotonic reading are immune to clock steps
+type Clock interface {
+	Now() time.Time
+}
+
+type sysClock struct{}
+
+func (sysClock) Now() time.Time { return time.Now() }
+
+// Options holds Router dependencies, nil fields are replaced with defaults
+type Options struct {
+	Logger    Logger
+	Transport Transport
+	FIB       FIB
+	Clock     Clock
+}
+
+// Router is a single RIPv2 instance
+type Router struct {
+	conf    atomic.Pointer[Config]
+	log     logger
+	trans   Transport
+	fib     FIB
+	clock   Clock
+	start   time.Time
+	addrs   *addrTable
+	ifaces  *ifTable
+	adj     *adjTable
+	nbr     *nbrTable
+	rx      rxStats
+	workers *workers
+	signal  *sign
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+	err     error
+}
+
+type sign struct {
+	resetAdj chan struct{}
+	resetNbr chan struct{}
+	resetIfc chan struct{}
+	resetFib chan struct{}
+	getAdj   chan struct{}
+	getNbr   chan struct{}
+}
+
+// New builds Router from config, transport and FIB are opened when not given
+func New(conf *Config, opt Options) (*Router, error) {
+	var err error
+	if conf == nil {
+		return nil, errors.New("config is required")
+	}
+
+	r := &Router{
+		trans: opt.Transport,
+		fib:   opt.FIB,
+		clock: opt.Clock,
+		done:  make(chan struct{}),
+	}
+	r.rx.entry = make(map[int]map[error]uint64)
+	r.signal = &sign{
+		resetAdj: make(chan struct{}, 1),
+		resetNbr: make(chan struct{}, 1),
+		resetIfc: make(chan struct{}, 1),
+		resetFib: make(chan struct{}, 1),
+		getAdj:   make(chan struct{}, 1),
+		getNbr:   make(chan struct{}, 1),
+	}
+
+	out := opt.Logger
+	if out == nil {
+		out = NewLogger()
+	}
+	r.log = logger{out: out, level: func() uint8 {
+		if conf := r.config(); conf != nil {
+			return conf.Global.Log
+		}
+		return debug
+	}}
+
+	compiled, err := conf.compile()
+	if err != nil {
+		return nil, err
+	}
+	r.conf.Store(compiled)
+
+	if r.clock == nil {
+		r.clock = sysClock{}
+	}
+	r.start = r.clock.Now()
+	if r.fib == nil {
+		if r.fib, err = r.newFib(&r.config().Global); err != nil {
+			return nil, err
+		}
+	}
+	if r.trans == nil {
+		if r.trans, err = NewSocket(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Start flushes stale routes, enables interfaces and starts protocol processing
+func (r *Router) Start() error {
+	var err error
+	r.log.send(info, "starting router")
+
+	if err = r.clrRoutes(); err != nil {
+		r.log.send(erro, err)
+	}
+
+	if r.addrs, err = r.initAddrTable(); err != nil {
+		return err
+	}
+	if r.ifaces, err = r.initIfTable(); err != nil {
+		return err
+	}
+
+	r.adj = r.initAdjTable()
+	r.nbr = r.initNbrTable()
+
+	r.workers = r.initWorkers()
+
+	r.spawn(func() { r.ifaces.subscribe(r.adj) })
+	r.spawn(r.receive)
+
+	return nil
+}
+
+// Stop halts processing, withdraws installed routes and closes transport
+func (r *Router) Stop() {
+	r.stop(nil)
+}
+
+func (r *Router) stop(err error) {
+	r.once.Do(func() {
+		r.err = err
+		close(r.done)
+		if r.ifaces != nil {
+			for ifi := range r.ifaces.list() {
+				r.trans.Leave(ifi)
+			}
+		}
+		r.trans.Close()
+		//Nothing may install routes after the flush
+		r.wg.Wait()
+		if err := r.clrRoutes(); err != nil {
+			r.log.send(erro, err)
+		}
+		r.log.send(info, "router stopped")
+	})
+}
+
+// spawn runs long running fn, stop waits for all of them before flushing FIB
+func (r *Router) spawn(fn func()) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		fn()
+	}()
+}
+
+// Done is closed when Router stops
+func (r *Router) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err reports why Router stopped, nil after Stop
+func (r *Router) Err() error {
+	return r.err
+}
+
+// Reload applies only what differs from running config keeping learned
+// routes and neighbors, invalid config is rejected and the running one kept
+func (r *Router) Reload(conf *Config) error {
+	compiled, err := conf.compile()
+	if err != nil {
+		return err
+	}
+	old := r.config()
+	r.conf.Store(compiled)
+
+	g, og := compiled.Global, old.Global
+	if g.Workers != og.Workers || g.QueueSize != og.QueueSize || g.FIB != og.FIB || g.FIBFile != og.FIBFile {
+		r.log.send(warn, "workers, queueSize and FIB backend changes take effect after restart")
+	}
+	if !reflect.DeepEqual(old.Interfaces, compiled.Interfaces) || !reflect.DeepEqual(old.networks, compiled.networks) {
+		r.notify(r.signal.resetIfc)
+	}
+	if !reflect.DeepEqual(old.nbrs, compiled.nbrs) {
+		r.notify(r.signal.resetNbr)
+	}
+	if old.poll() != compiled.poll() {
+		r.notify(r.signal.resetAdj)
+	}
+	if old.Timers.ReconcileTimer != compiled.Timers.ReconcileTimer || g.Metric != og.Metric {
+		r.notify(r.signal.resetFib)
+	}
+	r.log.send(info, "config reloaded")
+	return nil
+}
+
+// DumpAdj prints adjustments table to log
+func (r *Router) DumpAdj() {
+	r.notify(r.signal.getAdj)
+}
+
+// DumpNbr prints neighbors table to log
+func (r *Router) DumpNbr() {
+	r.notify(r.signal.getNbr)
+}
+
+// config returns current config snapshot, it is replaced as a whole and never
+// modified, so functions reading several fields take one snapshot up front
+func (r *Router) config() *Config {
+	return r.conf.Load()
+}
+
+// age is time passed since t for operator output, measured by the same
+// clock timestamps are taken from
+func (r *Router) age(t time.Time) time.Duration {
+	if t.IsZero() {
+		return 0
+	}
+	return r.clock.Now().Sub(t).Round(time.Second)
+}
+
+// wall formats wall-clock part of t for operator output
+func wall(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Round(0).Format(time.DateTime)
+}
+
+// notify never blocks, pending signal of the same kind already covers this one
+// and a consumer that is not running must not stall Reload or signal handling
+func (r *Router) notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,93 @@
+package rip
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// loadKeys resolves keys given by file, environment or command into AuthKey,
+// resolved sources are cleared so keys are read once per config load
+func (c *Config) loadKeys() (errs []error) {
+	for name, ifc := range c.Interfaces {
+		if err := ifc.KeyChain.load(); err != nil {
+			errs = append(errs, fmt.Errorf("interfaces.%q.keychain: %w", name, err))
+		}
+		c.Interfaces[name] = ifc
+	}
+	for ipn, nbr := range c.Neighbors {
+		if err := nbr.KeyChain.load(); err != nil {
+			errs = append(errs, fmt.Errorf("neighbors.%q.keychain: %w", ipn, err))
+		}
+		c.Neighbors[ipn] = nbr
+	}
+	return
+}
+
+func (k *KeyChain) load() error {
+	var sources int
+	for _, s := range []string{k.AuthKey, k.AuthKeyFile, k.AuthKeyEnv, k.AuthKeyCommand} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of authKey, authKeyFile, authKeyEnv, authKeyCommand may be set")
+	}
+
+	var (
+		key string
+		err error
+	)
+	switch {
+	case k.AuthKeyFile != "":
+		key, err = readKeyFile(k.AuthKeyFile)
+	case k.AuthKeyEnv != "":
+		var ok bool
+		if key, ok = os.LookupEnv(k.AuthKeyEnv); !ok {
+			err = fmt.Errorf("authKeyEnv: %s is not set", k.AuthKeyEnv)
+		}
+	case k.AuthKeyCommand != "":
+		key, err = runKeyCommand(k.AuthKeyCommand)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	k.AuthKey = strings.TrimRight(key, "\r\n")
+	k.AuthKeyFile, k.AuthKeyEnv, k.AuthKeyCommand = "", "", ""
+	return nil
+}
+
+// readKeyFile refuses files writable by group or accessible by others
+func readKeyFile(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("authKeyFile: %w", err)
+	}
+	if fi.Mode().Perm()&0o027 != 0 {
+		return "", fmt.Errorf("authKeyFile: %s has mode %v, must not be group writable or accessible by others", path, fi.Mode().Perm())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("authKeyFile: %w", err)
+	}
+	return string(b), nil
+}
+
+// runKeyCommand runs command without shell, output is never part of error
+func runKeyCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("authKeyCommand: empty command")
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("authKeyCommand: %s: %v", args[0], err)
+	}
+	return string(out), nil
+}
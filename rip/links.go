@@ -0,0 +1,206 @@
+package rip
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+type ifTable struct {
+	r     *Router
+	entry map[int]*ifEntry
+	mux   sync.RWMutex
+}
+
+type ifEntry struct {
+	name  string
+	param Interface
+	named bool
+}
+
+func (r *Router) initIfTable() (*ifTable, error) {
+	t := &ifTable{r: r}
+	t.entry = make(map[int]*ifEntry)
+
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		t.update(link.Attrs().Index, link.Attrs().Name)
+	}
+
+	return t, nil
+}
+
+func (t *ifTable) get(ifi int) (Interface, bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if e, ok := t.entry[ifi]; ok {
+		return e.param, true
+	}
+	return Interface{}, false
+}
+
+func (t *ifTable) named(ifi int) bool {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if e, ok := t.entry[ifi]; ok {
+		return e.named
+	}
+	return false
+}
+
+func (t *ifTable) list() map[int]Interface {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	l := make(map[int]Interface, len(t.entry))
+	for ifi, e := range t.entry {
+		l[ifi] = e.param
+	}
+	return l
+}
+
+// update enables, disables or refreshes an interface against current config
+func (t *ifTable) update(ifi int, name string) (up, down bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	param, conf := t.r.config().ifcByName(name)
+	named := conf
+	if !conf {
+		conf = t.r.inNetworks(ifi)
+	}
+	e, ok := t.entry[ifi]
+	switch {
+	case conf && !ok:
+		if err := t.r.trans.Join(ifi); err != nil {
+			t.r.log.send(erro, err)
+			return
+		}
+		t.entry[ifi] = &ifEntry{name: name, param: param, named: named}
+		t.r.log.send(info, "interface "+name+" enabled")
+		return true, false
+	case !conf && ok:
+		t.r.trans.Leave(ifi)
+		delete(t.entry, ifi)
+		t.r.log.send(info, "interface "+e.name+" disabled")
+		return false, true
+	case conf && ok:
+		e.name = name
+		e.param = param
+		e.named = named
+	}
+	return
+}
+
+func (t *ifTable) remove(ifi int) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	e, ok := t.entry[ifi]
+	if !ok {
+		return false
+	}
+	t.r.trans.Leave(ifi)
+	delete(t.entry, ifi)
+	t.r.log.send(info, "interface "+e.name+" removed")
+	return true
+}
+
+func (t *ifTable) sync(a *adjTable) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		t.r.log.send(erro, err)
+		return
+	}
+
+	present := make(map[int]bool, len(links))
+	for _, link := range links {
+		present[link.Attrs().Index] = true
+		t.apply(a, link.Attrs().Index, link.Attrs().Name)
+	}
+	for ifi := range t.list() {
+		if !present[ifi] && t.remove(ifi) {
+			a.dropIfi(ifi)
+		}
+	}
+	//Advertised addresses depend on network statement
+	for ifi := range t.list() {
+		a.refreshLocal(ifi)
+	}
+}
+
+func (t *ifTable) apply(a *adjTable, ifi int, name string) {
+	switch up, down := t.update(ifi, name); {
+	case up:
+		l, err := t.r.getTable(ifi)
+		if err != nil {
+			t.r.log.send(erro, err)
+		} else {
+			a.procIncom(l)
+		}
+		t.r.reqGiveIfi(ifi)
+	case down:
+		a.dropIfi(ifi)
+	}
+}
+
+func (t *ifTable) subscribe(a *adjTable) {
+	ch := make(chan netlink.LinkUpdate)
+	ach := make(chan netlink.AddrUpdate)
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := netlink.LinkSubscribe(ch, done); err != nil {
+		t.r.log.send(erro, err)
+		return
+	}
+	if err := netlink.AddrSubscribe(ach, done); err != nil {
+		t.r.log.send(erro, err)
+		return
+	}
+	//Links and addresses could appear between initial dump and subscription
+	if err := t.r.addrs.sync(); err != nil {
+		t.r.log.send(erro, err)
+	}
+	t.sync(a)
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				t.r.log.send(erro, "link subscription closed")
+				return
+			}
+			switch msg.Header.Type {
+			case syscall.RTM_NEWLINK:
+				t.apply(a, msg.Attrs().Index, msg.Attrs().Name)
+			case syscall.RTM_DELLINK:
+				t.r.addrs.drop(msg.Attrs().Index)
+				if t.remove(msg.Attrs().Index) {
+					a.dropIfi(msg.Attrs().Index)
+				}
+			}
+		case msg, ok := <-ach:
+			if !ok {
+				t.r.log.send(erro, "address subscription closed")
+				return
+			}
+			t.r.addrs.update(msg)
+			link, err := netlink.LinkByIndex(msg.LinkIndex)
+			if err != nil {
+				continue
+			}
+			t.apply(a, msg.LinkIndex, link.Attrs().Name)
+			if _, ok := t.get(msg.LinkIndex); ok {
+				a.refreshLocal(msg.LinkIndex)
+			}
+		case <-t.r.signal.resetIfc:
+			t.sync(a)
+		case <-t.r.done:
+			return
+		}
+	}
+}
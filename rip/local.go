@@ -0,0 +1,108 @@
+package rip
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+func (r *Router) getTable(ifi int) (*pdu, error) {
+	pdu := &pdu{
+		header: header{Version: 2, Command: response},
+		serviceFields: &serviceFields{
+			ip:        binary.BigEndian.Uint32([]byte{127, 0, 0, 1}),
+			ifi:       ifi,
+			timestamp: r.clock.Now(),
+		},
+	}
+	conf := r.config()
+	named := r.ifaces.named(ifi)
+	for _, ipAddr := range r.addrs.list(ifi) {
+		if ipAddr.IP.IsLoopback() {
+			continue
+		}
+		//Interfaces enabled by network statement advertise matching addresses only
+		if !named && !conf.inNetworks(ipAddr.IP) {
+			continue
+		}
+		pdu.routeEntries = append(pdu.routeEntries, routeEntry{
+			AFI:     afiIPv4,
+			Network: binary.BigEndian.Uint32(ipAddr.IP.Mask(ipAddr.Mask)),
+			Mask:    binary.BigEndian.Uint32(ipAddr.Mask),
+		})
+	}
+	return pdu, nil
+}
+
+func (r *Router) inNetworks(ifi int) bool {
+	conf := r.config()
+	if len(conf.networks) == 0 {
+		return false
+	}
+
+	for _, ipAddr := range r.addrs.list(ifi) {
+		if conf.inNetworks(ipAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// srcAddr picks source address for packets sent to dst via ifi,
+// an address on dst subnet wins over the first advertised one.
+// Zero ifi searches all interfaces, nil result leaves choice to kernel
+func (r *Router) srcAddr(ifi int, dst net.IP) net.IP {
+	var src net.IP
+	conf := r.config()
+	for _, ipAddr := range r.addrs.list(ifi) {
+		if ipAddr.IP.IsLoopback() {
+			continue
+		}
+		if dst != nil && ipAddr.Contains(dst) {
+			return ipAddr.IP
+		}
+		if src == nil && (ifi == 0 || r.ifaces.named(ifi) || conf.inNetworks(ipAddr.IP)) {
+			src = ipAddr.IP
+		}
+	}
+	if dst != nil && ifi == 0 {
+		//Unicast off-link, let routing decide
+		return nil
+	}
+	return src
+}
+
+func (r *Router) route(netid ipNet, nextHop uint32) Route {
+	return Route{
+		Dst: &net.IPNet{
+			IP:   uintToIP(netid.IP),
+			Mask: net.IPMask(uintToIP(netid.Mask)),
+		},
+		Gw:     uintToIP(nextHop),
+		Metric: r.config().Global.Metric,
+	}
+}
+
+func (r *Router) addRoute(netid ipNet, nextHop uint32) error {
+	if uintToIP(nextHop).IsLoopback() {
+		return nil
+	}
+	return r.fib.Add(r.route(netid, nextHop))
+}
+
+func (r *Router) replRoute(netid ipNet, nextHop uint32) error {
+	if uintToIP(nextHop).IsLoopback() {
+		return nil
+	}
+	return r.fib.Replace(r.route(netid, nextHop))
+}
+
+func (r *Router) remRoute(netid ipNet) error {
+	route := r.route(netid, 0)
+	route.Gw = nil
+	route.Metric = 0
+	return r.fib.Remove(route)
+}
+
+func (r *Router) clrRoutes() error {
+	return r.fib.Flush()
+}
@@ -0,0 +1,84 @@
+package rip
+
+import (
+	"fmt"
+	"log"
+)
+
+// Log levels, messages above configured Global.Log are dropped
+const (
+	LogUser uint8 = iota
+	LogFatal
+	LogError
+	LogWarn
+	LogInfo
+	LogDebug
+)
+
+const (
+	user  = LogUser
+	fatal = LogFatal
+	erro  = LogError
+	warn  = LogWarn
+	info  = LogInfo
+	debug = LogDebug
+)
+
+var levels = []string{
+	"user",
+	"fatal",
+	"error",
+	"warn",
+	"info",
+	"debug",
+}
+
+// Logger receives already filtered and formatted messages
+type Logger interface {
+	Log(level uint8, msg string)
+}
+
+type stdLogger chan<- logEntry
+
+type logEntry struct {
+	level   uint8
+	message string
+}
+
+// NewLogger returns Logger writing through standard log package
+func NewLogger() Logger {
+	logChan := make(chan logEntry, 4)
+
+	go func() {
+		for l := range logChan {
+			log.Printf("[%v] %v", levels[l.level], l.message)
+		}
+	}()
+
+	return stdLogger(logChan)
+}
+
+func (l stdLogger) Log(level uint8, msg string) {
+	l <- logEntry{level, msg}
+}
+
+type logger struct {
+	out   Logger
+	level func() uint8
+}
+
+func (l logger) send(lv uint8, msg interface{}) {
+	if lv > l.level() {
+		return
+	}
+
+	switch msg.(type) {
+	case error:
+		l.out.Log(lv, msg.(error).Error())
+	case string:
+		l.out.Log(lv, msg.(string))
+	case *pdu:
+		m := fmt.Sprintf("%+v\n", msg)
+		l.out.Log(lv, m)
+	}
+}
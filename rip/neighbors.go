@@ -0,0 +1,173 @@
+package rip
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type nbrTable struct {
+	r     *Router
+	entry map[uint32]*nbr
+	mux   sync.Mutex
+}
+
+const (
+	state uint8 = 1 << iota
+	static
+	auth
+)
+
+type nbr struct {
+	flags     uint8
+	timestamp time.Time
+}
+
+func (n *nbr) describe(r *Router) string {
+	m := ""
+	if n.flags&state != 0 {
+		m += "up "
+	} else {
+		m += "down "
+	}
+
+	if n.flags&static != 0 {
+		m += "static "
+	}
+	if n.flags&auth != 0 {
+		m += "auth "
+	}
+
+	return fmt.Sprintf("uptime: %v since: %v | %s", r.age(n.timestamp), wall(n.timestamp), m)
+}
+
+func (r *Router) initNbrTable() *nbrTable {
+	n := &nbrTable{r: r}
+	n.entry = make(map[uint32]*nbr)
+	n.syncStatic()
+	n.r.spawn(n.scheduler)
+
+	return n
+}
+
+func (n *nbrTable) scheduler() {
+	tWorker := time.NewTicker(5 * time.Second)
+	defer tWorker.Stop()
+	for {
+		select {
+		case <-tWorker.C:
+			n.clear()
+		case <-n.r.signal.getNbr:
+			n.r.log.send(user, n.dump())
+			n.r.log.send(user, n.r.rx.String())
+		case <-n.r.signal.resetNbr:
+			added, removed := n.syncStatic()
+			for _, ip := range removed {
+				n.r.adj.dropNbr(ip)
+			}
+			for _, ip := range added {
+				n.r.reqGiveNbr(ip)
+			}
+		case <-n.r.done:
+			return
+		}
+	}
+}
+
+// dump formats neighbors table, entries are copied under lock
+func (n *nbrTable) dump() string {
+	n.mux.Lock()
+	rows := make(map[uint32]nbr, len(n.entry))
+	for ip, opt := range n.entry {
+		rows[ip] = *opt
+	}
+	n.mux.Unlock()
+
+	var b strings.Builder
+	b.WriteString("Neighbors:\n")
+	for ip, opt := range rows {
+		fmt.Fprintf(&b, "%v\t%s\n", uintToIP(ip), opt.describe(n.r))
+	}
+	return b.String()
+}
+
+func (n *nbrTable) update(ip uint32, ifi int) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	ctime := n.r.clock.Now()
+	if n.entry[ip] == nil {
+		n.entry[ip] = &nbr{
+			flags:     state,
+			timestamp: ctime,
+		}
+	} else {
+		n.entry[ip].flags |= state
+		n.entry[ip].timestamp = ctime
+	}
+
+	if n.entry[ip].flags&static != 0 {
+		return
+	}
+
+	if ifc, _ := n.r.ifaces.get(ifi); ifc.KeyChain.AuthType != 0 {
+		n.entry[ip].flags |= auth
+	} else {
+		n.entry[ip].flags &^= auth
+	}
+}
+
+func (n *nbrTable) clear() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	ctime := n.r.clock.Now()
+	for ip, opt := range n.entry {
+		switch {
+		case n.entry[ip].flags&state != 0:
+			if ctime.Sub(opt.timestamp) > 600*time.Second {
+				n.entry[ip].flags &^= state
+			}
+		case n.entry[ip].flags&static != 0:
+			continue
+		case n.entry[ip].flags&state == 0:
+			if ctime.Sub(opt.timestamp) > 3600*time.Second {
+				delete(n.entry, ip)
+			}
+		}
+	}
+}
+
+// syncStatic aligns static neighbors with config keeping learned state
+func (n *nbrTable) syncStatic() (added, removed []uint32) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	nbrs := n.r.config().nbrs
+
+	for ip, e := range n.entry {
+		if _, ok := nbrs[ip]; ok || e.flags&static == 0 {
+			continue
+		}
+		removed = append(removed, ip)
+		e.flags &^= static | auth
+		if e.flags&state == 0 {
+			delete(n.entry, ip)
+		}
+	}
+
+	for ip, opt := range nbrs {
+		if n.entry[ip] == nil {
+			n.entry[ip] = &nbr{}
+		}
+		if n.entry[ip].flags&static == 0 {
+			added = append(added, ip)
+		}
+		n.entry[ip].flags |= static
+
+		if opt.KeyChain.AuthType != 0 {
+			n.entry[ip].flags |= auth
+		} else {
+			n.entry[ip].flags &^= auth
+		}
+	}
+	return
+}
@@ -0,0 +1,192 @@
+package rip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/k-danil/ripv2-go/codec"
+)
+
+const (
+	entrySize  = codec.EntrySize
+	headerSize = codec.HeaderSize
+)
+
+const (
+	authNon   = codec.AuthNone
+	authPlain = codec.AuthPlain
+	authHash  = codec.AuthHash
+)
+
+const (
+	afiIPv4    = codec.AFIIPv4
+	afiGiveAll = codec.AFIGiveAll
+)
+
+var (
+	errLoop     = errors.New("looped packet")
+	errSource   = errors.New("packet with unspecified source")
+	errOversize = errors.New("packet larger than receive buffer")
+	errVersion  = errors.New("incorrect RIP version (use 2)")
+	errAuthType = errors.New("incorrect AuthType")
+)
+
+type rxStats struct {
+	entry map[int]map[error]uint64
+	mux   sync.Mutex
+}
+
+type packet struct {
+	src       uint32
+	ifi       int
+	timestamp time.Time
+	content   []byte
+	keyChain  KeyChain
+}
+
+type pdu struct {
+	serviceFields *serviceFields
+	header        header
+	routeEntries  []routeEntry
+	auth          codec.Auth
+	raw           []byte
+}
+
+type header = codec.Header
+
+type routeEntry = codec.RouteEntry
+
+type serviceFields struct {
+	ip        uint32
+	authType  uint16
+	ifi       int
+	timestamp time.Time
+}
+
+// String formats pdu for debug log leaving authentication key out
+func (p *pdu) String() string {
+	return fmt.Sprintf("%+v %+v auth:{Type:%v KeyID:%v SQN:%v} entries:%+v",
+		p.header, *p.serviceFields, p.auth.Type, p.auth.KeyID, p.auth.SQN, p.routeEntries)
+}
+
+// rxState is parse state owned by one worker and reused for every packet,
+// nothing of it may be kept once the packet is handled
+type rxState struct {
+	pkt     codec.Packet
+	pdu     pdu
+	service serviceFields
+}
+
+// readPacket accepts packet from static neighbor or enabled interface and
+// picks keychain it is validated with, all from one config snapshot
+func (r *Router) readPacket(conf *Config, content []byte, ifi int, src uint32) (packet, error) {
+	if r.addrs.isLocal(src) {
+		return packet{}, errLoop
+	}
+
+	var keyChain KeyChain
+	if nbr, ok := conf.nbrs[src]; ok {
+		keyChain = nbr.KeyChain
+	} else if ifc, ok := r.ifaces.get(ifi); ok {
+		keyChain = ifc.KeyChain
+	} else {
+		return packet{}, errSource
+	}
+
+	return packet{
+		src:       src,
+		ifi:       ifi,
+		timestamp: r.clock.Now(),
+		content:   content,
+		keyChain:  keyChain,
+	}, nil
+}
+
+func (p *packet) parse(st *rxState, max int) (*pdu, error) {
+	if err := codec.UnmarshalInto(&st.pkt, p.content, max); err != nil {
+		return nil, err
+	}
+
+	st.service = serviceFields{
+		ip:        p.src,
+		ifi:       p.ifi,
+		authType:  st.pkt.Auth.Type,
+		timestamp: p.timestamp,
+	}
+	st.pdu = pdu{
+		serviceFields: &st.service,
+		header:        st.pkt.Header,
+		routeEntries:  st.pkt.Entries,
+		auth:          st.pkt.Auth,
+		raw:           p.content,
+	}
+	return &st.pdu, nil
+}
+
+func (p *pdu) validate(keyChain KeyChain, log logger) error {
+	if p.header.Version != 2 {
+		return errVersion
+	}
+
+	if p.serviceFields.authType == keyChain.AuthType {
+		pkt := codec.Packet{Header: p.header, Auth: p.auth, Entries: p.routeEntries}
+		if err := codec.VerifyBytes(&pkt, p.raw, keyChain.AuthKey); err != nil {
+			return err
+		}
+	} else {
+		return errAuthType
+	}
+
+	if p.header.Command == response {
+		for l := 0; l < len(p.routeEntries); l++ {
+			if p.routeEntries[l].Metric > infMetric {
+				p.routeEntries[l].Metric = invMetric
+				log.send(warn, fmt.Sprintf("route entry %v marked invalid", uintToIP(p.routeEntries[l].Network)))
+			} else if p.routeEntries[l].Network != 0 && !uintToIP(p.routeEntries[l].Network).IsGlobalUnicast() {
+				p.routeEntries[l].Metric = invMetric
+				log.send(warn, fmt.Sprintf("route entry %v marked invalid", uintToIP(p.routeEntries[l].Network)))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *rxStats) count(ifi int, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var perr *codec.ParseError
+	if errors.As(err, &perr) {
+		err = perr.Err
+	}
+	if s.entry[ifi] == nil {
+		s.entry[ifi] = make(map[error]uint64)
+	}
+	s.entry[ifi][err]++
+}
+
+func (s *rxStats) String() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	m := "Dropped packets:\n"
+	for ifi, errs := range s.entry {
+		for err, c := range errs {
+			m += fmt.Sprintf("ifi:%v %v: %v\n", ifi, err, c)
+		}
+	}
+	return m
+}
+
+func uintToIP(ip uint32) net.IP {
+	result := make(net.IP, 4)
+	result[3] = byte(ip)
+	result[2] = byte(ip >> 8)
+	result[1] = byte(ip >> 16)
+	result[0] = byte(ip >> 24)
+
+	return result
+}
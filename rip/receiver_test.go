@@ -0,0 +1,81 @@
+package rip
+
+import (
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Log(uint8, string) {}
+
+const benchKey = "secret"
+
+// benchPdu is a full response carrying entries routes
+func benchPdu(entries int, authType uint16) *pdu {
+	p := &pdu{
+		header:        header{Command: response, Version: 2},
+		routeEntries:  make([]routeEntry, entries),
+		serviceFields: &serviceFields{authType: authType},
+	}
+	for i := range p.routeEntries {
+		p.routeEntries[i] = routeEntry{
+			AFI:     afiIPv4,
+			Network: 10<<24 | uint32(i)<<8,
+			Mask:    0xffffff00,
+			Metric:  1,
+		}
+	}
+	return p
+}
+
+func TestParseReusesState(t *testing.T) {
+	var st rxState
+	log := logger{out: nopLogger{}, level: func() uint8 { return LogUser }}
+	for _, authType := range []uint16{authNon, authPlain, authHash} {
+		raw, err := benchPdu(25, authType).toByte(nil, benchKey, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkt := packet{src: 1, ifi: 2, timestamp: time.Now(), content: raw}
+		p, err := pkt.parse(&st, 25)
+		if err != nil {
+			t.Fatalf("authType %d: %v", authType, err)
+		}
+		if err := p.validate(KeyChain{AuthType: authType, AuthKey: benchKey}, log); err != nil {
+			t.Fatalf("authType %d: %v", authType, err)
+		}
+		if len(p.routeEntries) != 25 || p.serviceFields.ip != 1 || p.serviceFields.ifi != 2 {
+			t.Fatalf("authType %d: got %v", authType, p)
+		}
+	}
+}
+
+func benchmarkParse(b *testing.B, authType uint16) {
+	raw, err := benchPdu(25, authType).toByte(nil, benchKey, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	keyChain := KeyChain{AuthType: authType, AuthKey: benchKey}
+	log := logger{out: nopLogger{}, level: func() uint8 { return LogUser }}
+	pkt := packet{src: 1, ifi: 1, timestamp: time.Now(), content: raw}
+	var st rxState
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(raw)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, err := pkt.parse(&st, 25)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := p.validate(keyChain, log); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.Run("plain", func(b *testing.B) { benchmarkParse(b, authNon) })
+	b.Run("hash", func(b *testing.B) { benchmarkParse(b, authHash) })
+}
@@ -0,0 +1,147 @@
+package rip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type driftStats struct {
+	runs     uint64
+	missing  uint64
+	stale    uint64
+	mismatch uint64
+	failed   uint64
+}
+
+func (d *driftStats) String() string {
+	return fmt.Sprintf(
+		"FIB reconcile: runs:%v missing:%v stale:%v mismatch:%v failed:%v",
+		atomic.LoadUint64(&d.runs), atomic.LoadUint64(&d.missing), atomic.LoadUint64(&d.stale),
+		atomic.LoadUint64(&d.mismatch), atomic.LoadUint64(&d.failed),
+	)
+}
+
+// reconciler keeps FIB in sync, metric is kernel priority routes were installed with
+func (a *adjTable) reconciler(metric int) {
+	var ch chan struct{}
+	done := make(chan struct{})
+	defer close(done)
+
+	if w, ok := a.r.fib.(FIBWatcher); ok {
+		ch = make(chan struct{}, 1)
+		if err := w.Watch(ch, done); err != nil {
+			a.r.log.send(warn, err)
+			ch = nil
+		}
+	}
+
+	tReconcile := time.NewTicker(time.Duration(a.r.config().Timers.ReconcileTimer))
+	defer tReconcile.Stop()
+	//Route events come in bursts, wait for them to settle
+	var settle <-chan time.Time
+
+	for {
+		select {
+		case <-tReconcile.C:
+			a.reconcile()
+		case <-ch:
+			if settle == nil {
+				settle = time.After(time.Second)
+			}
+		case <-settle:
+			settle = nil
+			a.reconcile()
+		case <-a.r.signal.resetFib:
+			conf := a.r.config()
+			tReconcile.Reset(time.Duration(conf.Timers.ReconcileTimer))
+			if conf.Global.Metric != metric {
+				a.reinstall(metric)
+				metric = conf.Global.Metric
+			}
+		case <-a.r.done:
+			return
+		}
+	}
+}
+
+func (a *adjTable) installed() (want map[ipNet]uint32, skip map[ipNet]bool) {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	want = make(map[ipNet]uint32, a.entries.len())
+	skip = make(map[ipNet]bool)
+
+	a.entries.walk(func(netid ipNet, opt *adj) {
+		switch {
+		case uintToIP(opt.nextHop).IsLoopback():
+			skip[netid] = true
+		//Dead routes stay in kernel until garbage collection
+		case opt.kill || opt.metric >= infMetric:
+			skip[netid] = true
+		default:
+			want[netid] = opt.nextHop
+		}
+	})
+	return
+}
+
+// reinstall moves installed routes from old kernel priority to the current one,
+// replace matches on priority too and would leave routes of old one behind
+func (a *adjTable) reinstall(old int) {
+	want, _ := a.installed()
+	for netid, nh := range want {
+		route := a.r.route(netid, 0)
+		route.Gw = nil
+		route.Metric = old
+		if err := a.r.fib.Remove(route); err != nil {
+			a.r.log.send(warn, err)
+		}
+		if err := a.r.replRoute(netid, nh); err != nil {
+			a.r.log.send(erro, err)
+		}
+	}
+}
+
+func (a *adjTable) reconcile() {
+	routes, err := a.r.fib.List()
+	if err != nil {
+		a.r.log.send(erro, err)
+		return
+	}
+	have := make(map[ipNet]uint32, len(routes))
+	for _, route := range routes {
+		var gw uint32
+		if route.Gw != nil {
+			gw = binary.BigEndian.Uint32(route.Gw.To4())
+		}
+		have[routeKey(route)] = gw
+	}
+	want, skip := a.installed()
+	atomic.AddUint64(&a.drift.runs, 1)
+
+	fix := func(err error, counter *uint64, msg string) {
+		atomic.AddUint64(counter, 1)
+		a.r.log.send(warn, msg)
+		if err != nil {
+			atomic.AddUint64(&a.drift.failed, 1)
+			a.r.log.send(erro, err)
+		}
+	}
+
+	for netid, nh := range want {
+		gw, ok := have[netid]
+		switch {
+		case !ok:
+			fix(a.r.addRoute(netid, nh), &a.drift.missing, fmt.Sprintf("FIB drift: %v missing, re-adding", netid))
+		case gw != nh:
+			fix(a.r.replRoute(netid, nh), &a.drift.mismatch, fmt.Sprintf("FIB drift: %v via %v instead of %v", netid, uintToIP(gw), uintToIP(nh)))
+		}
+	}
+	for netid := range have {
+		if _, ok := want[netid]; ok || skip[netid] {
+			continue
+		}
+		fix(a.r.remRoute(netid), &a.drift.stale, fmt.Sprintf("FIB drift: %v stale, removing", netid))
+	}
+}
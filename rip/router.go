@@ -0,0 +1,340 @@
+package rip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	change bool = true
+)
+
+const (
+	request  = 1
+	response = 2
+)
+
+const (
+	infMetric = 16
+	invMetric = 255
+)
+
+type adjTable struct {
+	r        *Router
+	entries  *trie
+	mux      sync.RWMutex
+	seq      uint64
+	trig     uint64
+	sent     map[dest]uint64
+	cache    map[dest]*encoded
+	cacheMux sync.Mutex
+	drift    driftStats
+	timers   timerHeap
+	wake     chan struct{}
+}
+
+type ipNet struct {
+	IP   uint32
+	Mask uint32
+}
+
+type adj struct {
+	nextHop   uint32
+	metric    uint32
+	ifi       int
+	timestamp time.Time
+	kill      bool
+	seq       uint64
+	timer     *routeTimer
+}
+
+func (a *adj) describe(r *Router) string {
+	return fmt.Sprintf(
+		"nextHop:%v ifn:%v metric:%v uptime:%v since:%v kill:%v seq:%v",
+		uintToIP(a.nextHop), a.ifi, a.metric, r.age(a.timestamp), wall(a.timestamp), a.kill, a.seq,
+	)
+}
+
+func (i ipNet) String() string {
+	s, _ := net.IPMask(uintToIP(i.Mask)).Size()
+	return fmt.Sprintf("%v/%v", uintToIP(i.IP), s)
+}
+
+func (r *Router) initAdjTable() *adjTable {
+	a := &adjTable{r: r}
+	a.entries = newTrie()
+	a.sent = make(map[dest]uint64)
+	a.wake = make(chan struct{}, 1)
+	metric := r.config().Global.Metric
+	r.spawn(a.scheduler)
+	r.spawn(a.expirer)
+	r.spawn(func() { a.reconciler(metric) })
+
+	for i := range r.ifaces.list() {
+		l, err := r.getTable(i)
+		if err != nil {
+			a.r.log.send(erro, err)
+		} else {
+			a.procIncom(l)
+		}
+	}
+
+	return a
+}
+
+func (a *adjTable) scheduler() {
+	a.r.log.send(info, "starting scheduler")
+	tWorker := time.NewTicker(a.r.config().poll())
+	defer tWorker.Stop()
+	next := make(map[dest]time.Time)
+	go a.r.reqGiveAll()
+	for {
+		select {
+		case <-tWorker.C:
+			if a.pending() {
+				go a.respUpdate(change)
+			}
+			due := a.due(next)
+			if len(due) == 0 {
+				continue
+			}
+			go a.periodic(due)
+
+			for d := range due {
+				if d.ifi == 0 {
+					continue
+				}
+				l, err := a.r.getTable(d.ifi)
+				if err != nil {
+					a.r.log.send(erro, err)
+				} else {
+					a.procIncom(l)
+				}
+			}
+		case <-a.r.signal.getAdj:
+			a.r.log.send(user, a.dump())
+			a.r.log.send(user, a.drift.String())
+		case <-a.r.done:
+			defer a.r.log.send(info, "stopping scheduler")
+			return
+		case <-a.r.signal.resetAdj:
+			tWorker.Reset(a.r.config().poll())
+		}
+	}
+}
+
+// dump formats adjustments table, routes are copied under lock and
+// formatted after release so large tables do not stall route processing
+func (a *adjTable) dump() string {
+	type row struct {
+		net ipNet
+		adj adj
+	}
+	a.mux.RLock()
+	rows := make([]row, 0, a.entries.len())
+	a.entries.walk(func(netid ipNet, opt *adj) {
+		rows = append(rows, row{net: netid, adj: *opt})
+	})
+	a.mux.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("Adjustments:\n")
+	for i := range rows {
+		fmt.Fprintf(&b, "%v %s\n", rows[i].net, rows[i].adj.describe(a.r))
+	}
+	return b.String()
+}
+
+// due returns destinations whose update timer expired and reschedules them,
+// new destinations wait a full interval
+func (a *adjTable) due(next map[dest]time.Time) map[dest]bool {
+	conf := a.r.config()
+	now := a.r.clock.Now()
+	due := make(map[dest]bool)
+	seen := make(map[dest]bool)
+
+	check := func(d dest, t Timers) {
+		seen[d] = true
+		at, ok := next[d]
+		if ok && now.Before(at) {
+			return
+		}
+		if ok {
+			due[d] = true
+		}
+		next[d] = now.Add(time.Duration(t.UpdateTimer))
+	}
+	for ip := range conf.nbrs {
+		check(dest{ip: ip}, conf.Timers)
+	}
+	//Passive interfaces are tracked too, their local routes need refresh
+	for ifi, opt := range a.r.ifaces.list() {
+		check(dest{ifi: ifi}, conf.timers(opt))
+	}
+	for d := range next {
+		if !seen[d] {
+			delete(next, d)
+		}
+	}
+	return due
+}
+
+func (a *adjTable) procIncom(p *pdu) {
+	switch p.header.Command {
+	case request:
+		a.reqProc(p)
+	case response:
+		a.respProc(p)
+	}
+}
+
+func (a *adjTable) reqProc(p *pdu) {
+	if len(p.routeEntries) == 0 {
+		return
+	}
+	if len(p.routeEntries) == 1 && p.routeEntries[0].Metric == infMetric && p.routeEntries[0].AFI == afiGiveAll {
+		a.respToGive(p)
+	} else {
+		a.respToReq(p)
+	}
+}
+
+func (a *adjTable) respProc(p *pdu) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	for _, pEnt := range p.routeEntries {
+		netid := ipNet{IP: pEnt.Network, Mask: pEnt.Mask}
+		if !validNet(netid) {
+			continue
+		}
+		//Default next-hop is 0.0.0.0 but it can be anything else,
+		//one not reachable directly or pointing to us means sender
+		var nh uint32
+		if pEnt.NextHop != 0 && !a.r.addrs.isLocal(pEnt.NextHop) && a.r.addrs.onLink(p.serviceFields.ifi, pEnt.NextHop) {
+			nh = pEnt.NextHop
+		} else {
+			nh = p.serviceFields.ip
+		}
+
+		metric := pEnt.Metric + 1
+
+		newAdj := func() *adj {
+			return &adj{
+				nextHop:   nh,
+				ifi:       p.serviceFields.ifi,
+				metric:    metric,
+				timestamp: p.serviceFields.timestamp,
+			}
+		}
+
+		cur := a.entries.get(netid)
+		switch {
+		case cur == nil:
+			if metric < infMetric {
+				a.set(netid, nil, newAdj())
+
+				err := a.r.addRoute(netid, nh)
+				if err != nil {
+					a.r.log.send(erro, err)
+				}
+			}
+		case cur.nextHop == nh && metric == infMetric:
+			if cur.metric != infMetric {
+				a.kill(netid, cur)
+			}
+
+		case cur.nextHop == nh && metric < cur.metric:
+			a.set(netid, cur, newAdj())
+
+		case cur.nextHop == nh && metric == cur.metric:
+			cur.timestamp = p.serviceFields.timestamp
+			if !cur.kill {
+				a.arm(netid, cur, a.timeout(cur))
+			}
+
+		case metric < cur.metric:
+			a.set(netid, cur, newAdj())
+
+			err := a.r.replRoute(netid, nh)
+			if err != nil {
+				a.r.log.send(erro, err)
+			}
+		}
+	}
+}
+
+// set replaces cur with e and starts its timeout, caller holds a.mux
+func (a *adjTable) set(netid ipNet, cur, e *adj) {
+	if cur != nil {
+		a.disarm(cur)
+	}
+	a.entries.insert(netid, e)
+	a.touch(e)
+	a.arm(netid, e, a.timeout(e))
+}
+
+// dropNbr kills routes via removed static neighbor unless they were
+// learned on an enabled interface where the neighbor is still accepted
+func (a *adjTable) dropNbr(ip uint32) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.entries.walk(func(netid ipNet, opt *adj) {
+		if _, ok := a.r.ifaces.get(opt.ifi); opt.nextHop == ip && !ok && !opt.kill {
+			a.kill(netid, opt)
+		}
+	})
+}
+
+// refreshLocal withdraws local routes of ifi no longer advertised and adds new ones
+func (a *adjTable) refreshLocal(ifi int) {
+	l, err := a.r.getTable(ifi)
+	if err != nil {
+		a.r.log.send(erro, err)
+		return
+	}
+	keep := make(map[ipNet]bool, len(l.routeEntries))
+	for _, e := range l.routeEntries {
+		keep[ipNet{IP: e.Network, Mask: e.Mask}] = true
+	}
+
+	a.mux.Lock()
+	a.entries.walk(func(netid ipNet, opt *adj) {
+		if opt.ifi == ifi && uintToIP(opt.nextHop).IsLoopback() && !opt.kill && !keep[netid] {
+			a.kill(netid, opt)
+		}
+	})
+	a.mux.Unlock()
+
+	a.procIncom(l)
+}
+
+func (a *adjTable) dropIfi(ifi int) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.entries.walk(func(netid ipNet, opt *adj) {
+		if opt.ifi == ifi && !opt.kill {
+			a.kill(netid, opt)
+		}
+	})
+}
+
+// touch records change of e in change log, caller holds a.mux
+func (a *adjTable) touch(e *adj) {
+	a.seq++
+	e.seq = a.seq
+}
+
+// pending reports changes made since last triggered update
+func (a *adjTable) pending() bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.seq == a.trig {
+		return false
+	}
+	a.trig = a.seq
+	return true
+}
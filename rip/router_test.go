@@ -0,0 +1,419 @@
+package rip
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k-danil/ripv2-go/codec"
+)
+
+// fakeTransport records written packets, reads block until Close
+type fakeTransport struct {
+	mux    sync.Mutex
+	sent   int
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{closed: make(chan struct{})}
+}
+
+func (t *fakeTransport) ReadBatch(ms []Message) (int, error) {
+	<-t.closed
+	return 0, net.ErrClosed
+}
+
+func (t *fakeTransport) WriteBatch(ms []Message) (int, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, m := range ms {
+		if _, err := codec.Unmarshal(m.Buf[:m.N]); err != nil {
+			return 0, err
+		}
+	}
+	t.sent += len(ms)
+	return len(ms), nil
+}
+
+func (t *fakeTransport) Join(int) error  { return nil }
+func (t *fakeTransport) Leave(int) error { return nil }
+
+func (t *fakeTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+type fakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+}
+
+const (
+	testIfi = 1
+	testNbr = 0xc0a80201 // 192.168.2.1
+	testSrc = 0xc0a80102 // 192.168.1.2
+)
+
+func testConfig(metric int) *Config {
+	return &Config{
+		Global:     Global{Metric: metric, Log: LogUser, Workers: 2},
+		Timers:     Timers{UpdateTimer: Duration(time.Second)},
+		Interfaces: map[string]Interface{"eth0": {}},
+		Neighbors:  map[string]Neighbor{"192.168.2.1": {}},
+	}
+}
+
+// newTestRouter wires Router as Start does with interface eth0 holding
+// 192.168.1.1/24 and no netlink access
+func newTestRouter(t *testing.T, conf *Config) (*Router, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Now()}
+	r, err := New(conf, Options{
+		Logger:    nopLogger{},
+		Transport: newFakeTransport(),
+		FIB:       NewDryRunFIB(nopLogger{}),
+		Clock:     clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.addrs = &addrTable{
+		r:     r,
+		entry: map[int][]net.IPNet{testIfi: {{IP: net.IPv4(192, 168, 1, 1).To4(), Mask: net.CIDRMask(24, 32)}}},
+		local: map[uint32]int{0xc0a80101: testIfi},
+	}
+	r.ifaces = &ifTable{r: r, entry: make(map[int]*ifEntry)}
+	r.ifaces.update(testIfi, "eth0")
+	r.adj = r.initAdjTable()
+	r.nbr = r.initNbrTable()
+	r.workers = r.initWorkers()
+	t.Cleanup(r.Stop)
+	return r, clock
+}
+
+func testResponse(t testing.TB, entries ...routeEntry) []byte {
+	p := &pdu{
+		header:        header{Command: response, Version: 2},
+		routeEntries:  entries,
+		serviceFields: &serviceFields{},
+	}
+	raw, err := p.toByte(nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func testRoute(ip uint32, l int, metric uint32) routeEntry {
+	return routeEntry{AFI: afiIPv4, Network: ip & maskOf(l), Mask: maskOf(l), Metric: metric}
+}
+
+// advertised reports metric of netid in cached full update to d
+func advertised(t *testing.T, a *adjTable, d dest, netid ipNet) (uint32, bool) {
+	t.Helper()
+	a.cacheMux.Lock()
+	defer a.cacheMux.Unlock()
+	c := a.cache[d]
+	if c == nil {
+		t.Fatalf("no full update cached for %+v", d)
+	}
+	for _, raw := range c.pkts {
+		p, err := codec.UnmarshalLimit(raw, maxEntryCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range p.Entries {
+			if e.Network == netid.IP && e.Mask == netid.Mask {
+				return e.Metric, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestRouteLifecycle(t *testing.T) {
+	r, clock := newTestRouter(t, testConfig(0))
+	var st rxState
+	netid := ipNet{IP: 10<<24 | 1<<16, Mask: maskOf(16)}
+	d := dest{ifi: testIfi}
+
+	r.handle(&st, testResponse(t, testRoute(netid.IP, 16, 1)), testIfi, testSrc)
+	r.adj.mux.RLock()
+	e := r.adj.entries.get(netid)
+	r.adj.mux.RUnlock()
+	if e == nil || e.metric != 2 || e.nextHop != testSrc {
+		t.Fatalf("route not learned: %v", e)
+	}
+	routes, _ := r.fib.List()
+	if len(routes) != 1 || routes[0].Metric != defaultLocalMetric {
+		t.Fatalf("FIB holds %+v", routes)
+	}
+
+	//Split horizon keeps the route off the interface it came from
+	r.adj.periodic(map[dest]bool{d: true, {ip: testNbr}: true})
+	if _, ok := advertised(t, r.adj, d, netid); ok {
+		t.Fatalf("route advertised back to its source interface")
+	}
+	if m, ok := advertised(t, r.adj, dest{ip: testNbr}, netid); !ok || m != 2 {
+		t.Fatalf("route advertised to neighbor with metric %d, present %v", m, ok)
+	}
+
+	clock.advance(defaultTimeoutTimer + time.Second)
+	r.adj.mux.Lock()
+	r.adj.expire()
+	r.adj.mux.Unlock()
+	r.adj.periodic(map[dest]bool{{ip: testNbr}: true})
+	if m, ok := advertised(t, r.adj, dest{ip: testNbr}, netid); !ok || m != infMetric {
+		t.Fatalf("timed out route advertised with metric %d, present %v", m, ok)
+	}
+
+	clock.advance(defaultGarbageTimer + time.Second)
+	r.adj.mux.Lock()
+	r.adj.expire()
+	r.adj.mux.Unlock()
+	r.adj.periodic(map[dest]bool{{ip: testNbr}: true})
+	if _, ok := advertised(t, r.adj, dest{ip: testNbr}, netid); ok {
+		t.Fatalf("deleted route still advertised")
+	}
+	if routes, _ := r.fib.List(); len(routes) != 0 {
+		t.Fatalf("FIB holds %+v after garbage collection", routes)
+	}
+}
+
+func TestHandleSource(t *testing.T) {
+	r, _ := newTestRouter(t, testConfig(0))
+	var st rxState
+	raw := testResponse(t, testRoute(10<<24, 8, 1))
+
+	//Static neighbor is accepted on any interface until removed by reload
+	r.handle(&st, raw, 2, testNbr)
+	if err := r.Reload(&Config{Interfaces: map[string]Interface{"eth0": {}}}); err != nil {
+		t.Fatal(err)
+	}
+	r.handle(&st, raw, 2, testNbr+1)
+	r.handle(&st, raw, 2, testNbr)
+
+	r.rx.mux.Lock()
+	dropped := r.rx.entry[2][errSource]
+	r.rx.mux.Unlock()
+	if dropped != 2 {
+		t.Fatalf("%d packets from unknown source dropped, want 2", dropped)
+	}
+	r.adj.mux.RLock()
+	e := r.adj.entries.get(ipNet{IP: 10 << 24, Mask: maskOf(8)})
+	r.adj.mux.RUnlock()
+	if e == nil || e.nextHop != testNbr {
+		t.Fatalf("route from static neighbor not learned: %v", e)
+	}
+}
+
+// Routes learned by packets in flight must not outlive Stop
+func TestStopFlushes(t *testing.T) {
+	r, _ := newTestRouter(t, testConfig(0))
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 2000; i++ {
+			raw := testResponse(t, testRoute(10<<24|uint32(i)<<8, 24, 1))
+			buf := rxPool.Get().(*[]byte)
+			n := copy(*buf, raw)
+			if !r.workers.dispatch(job{buf: buf, n: n, ifi: testIfi, src: testSrc}) {
+				rxPool.Put(buf)
+			}
+		}
+	}()
+	time.Sleep(time.Millisecond)
+	r.Stop()
+	<-sent
+
+	time.Sleep(50 * time.Millisecond)
+	if routes, _ := r.fib.List(); len(routes) != 0 {
+		t.Fatalf("%d routes left after Stop", len(routes))
+	}
+}
+
+// Uptime in dumps is measured by Router clock
+func TestDumpAge(t *testing.T) {
+	r, clock := newTestRouter(t, testConfig(0))
+	var st rxState
+	r.handle(&st, testResponse(t, testRoute(10<<24, 8, 1)), testIfi, testSrc)
+	clock.advance(90 * time.Second)
+
+	if d := r.adj.dump(); !strings.Contains(d, "10.0.0.0/8 ") || !strings.Contains(d, "uptime:1m30s") {
+		t.Fatalf("adjustments dump:\n%s", d)
+	}
+	if d := r.nbr.dump(); !strings.Contains(d, "192.168.1.2\tuptime: 1m30s") {
+		t.Fatalf("neighbors dump:\n%s", d)
+	}
+}
+
+func TestReloadMetric(t *testing.T) {
+	r, _ := newTestRouter(t, testConfig(0))
+	var st rxState
+	r.handle(&st, testResponse(t, testRoute(10<<24, 8, 1)), testIfi, testSrc)
+
+	if err := r.Reload(testConfig(20)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		routes, _ := r.fib.List()
+		if len(routes) == 1 && routes[0].Metric == 20 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("routes not moved to new metric: %+v", routes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	r, _ := newTestRouter(t, testConfig(0))
+	if err := r.Reload(testConfig(300)); err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	if r.config().Global.Metric != defaultLocalMetric {
+		t.Fatalf("running config replaced by invalid one")
+	}
+}
+
+// Signals must not block when nothing consumes them
+func TestNotifyNotStarted(t *testing.T) {
+	r, err := New(testConfig(0), Options{
+		Logger:    nopLogger{},
+		Transport: newFakeTransport(),
+		FIB:       NewDryRunFIB(nopLogger{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			conf := testConfig(10 + i)
+			conf.Interfaces["eth1"] = Interface{}
+			conf.Timers.UpdateTimer = Duration(time.Duration(i+1) * time.Second)
+			if err := r.Reload(conf); err != nil {
+				t.Error(err)
+			}
+			r.DumpAdj()
+			r.DumpNbr()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload blocked without running router")
+	}
+}
+
+// TestRouterConcurrent drives packet handling, updates, expiry, reload and
+// table dumps at once, it is meant to be run with -race. Seeds are fixed and
+// routes come from a small pool so table size and runtime stay bounded
+func TestRouterConcurrent(t *testing.T) {
+	r, clock := newTestRouter(t, testConfig(0))
+	var (
+		wg   sync.WaitGroup
+		seed int64
+	)
+	run := func(n, iterations int, fn func(rnd *rand.Rand)) {
+		for g := 0; g < n; g++ {
+			seed++
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(seed))
+				for i := 0; i < iterations; i++ {
+					fn(rnd)
+				}
+			}(seed)
+		}
+	}
+
+	run(4, 200, func(rnd *rand.Rand) {
+		var st rxState
+		entries := make([]routeEntry, 1+rnd.Intn(25))
+		for i := range entries {
+			entries[i] = testRoute(10<<24|uint32(rnd.Intn(256))<<8, 16+rnd.Intn(9), 1+uint32(rnd.Intn(16)))
+		}
+		src := uint32(testSrc + rnd.Intn(4))
+		r.handle(&st, testResponse(t, entries...), testIfi, src)
+
+		req := &pdu{
+			header:        header{Command: request, Version: 2},
+			routeEntries:  entries[:1],
+			serviceFields: &serviceFields{},
+		}
+		raw, _ := req.toByte(nil, "", 0)
+		r.handle(&st, raw, testIfi, src)
+	})
+	run(2, 50, func(rnd *rand.Rand) {
+		r.adj.periodic(map[dest]bool{{ifi: testIfi}: true, {ip: testNbr}: true})
+		r.adj.respUpdate(rnd.Intn(2) == 0)
+	})
+	run(1, 50, func(rnd *rand.Rand) {
+		clock.advance(time.Duration(rnd.Intn(20)) * time.Second)
+		r.adj.mux.Lock()
+		r.adj.expire()
+		r.adj.mux.Unlock()
+		r.adj.reconcile()
+	})
+	run(1, 20, func(rnd *rand.Rand) {
+		conf := testConfig(10 + rnd.Intn(2))
+		conf.Timers.UpdateTimer = Duration(time.Duration(1+rnd.Intn(3)) * time.Second)
+		if rnd.Intn(2) == 0 {
+			conf.Neighbors = nil
+		}
+		if err := r.Reload(conf); err != nil {
+			t.Error(err)
+		}
+		r.DumpAdj()
+		r.DumpNbr()
+		r.adj.refreshLocal(testIfi)
+	})
+	wg.Wait()
+
+	tr := r.trans.(*fakeTransport)
+	tr.mux.Lock()
+	if tr.sent == 0 {
+		t.Errorf("no packets written")
+	}
+	tr.mux.Unlock()
+
+	r.adj.mux.RLock()
+	defer r.adj.mux.RUnlock()
+	var prev ipNet
+	n := 0
+	r.adj.entries.walk(func(netid ipNet, e *adj) {
+		if n > 0 && netid == prev {
+			t.Fatalf("%v visited twice", netid)
+		}
+		prev = netid
+		n++
+		if !validNet(netid) || e.metric > infMetric {
+			t.Fatalf("corrupt route %v %v", netid, e)
+		}
+	})
+	if n != r.adj.entries.len() {
+		t.Fatalf("walk visited %d routes, table holds %d", n, r.adj.entries.len())
+	}
+}
@@ -0,0 +1,413 @@
+package rip
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/k-danil/ripv2-go/codec"
+)
+
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+var authEntries = map[uint16]int{
+	0: 0,
+	1: 0,
+	2: 1,
+	3: 2,
+}
+
+type filtFunc func(*adj) bool
+
+func (p *pdu) toByte(dst []byte, pass string, sqn uint32) ([]byte, error) {
+	pkt := codec.Packet{
+		Header:  p.header,
+		Entries: p.routeEntries,
+		Auth: codec.Auth{
+			Type:  p.serviceFields.authType,
+			KeyID: 1,
+			SQN:   sqn,
+		},
+	}
+	return codec.AppendMarshal(dst, &pkt, pass)
+}
+
+// batch collects encoded packets to be written with a single call
+type batch struct {
+	r     *Router
+	ms    []Message
+	bufs  []*[]byte
+	round []int
+	seen  map[dest]int
+}
+
+type dest struct {
+	ifi int
+	ip  uint32
+}
+
+func (r *Router) newBatch(size int) *batch {
+	return &batch{
+		r:     r,
+		ms:    make([]Message, 0, size),
+		bufs:  make([]*[]byte, 0, size),
+		round: make([]int, 0, size),
+		seen:  make(map[dest]int),
+	}
+}
+
+// add encodes p for interface ifi or unicast destination ip
+func (b *batch) add(p *pdu, pass string, ifi int, ip net.IP) {
+	b.r.log.send(debug, p)
+
+	buf := bufPool.Get().(*[]byte)
+	raw, err := p.toByte((*buf)[:0], pass, b.r.sqn())
+	if err != nil {
+		bufPool.Put(buf)
+		b.r.log.send(erro, err)
+		return
+	}
+	*buf = raw
+
+	b.push(raw, buf, ifi, ip, dest{ifi: p.serviceFields.ifi, ip: p.serviceFields.ip})
+}
+
+// addRaw queues copy of pre-encoded packet for d with fresh SQN and digest
+func (b *batch) addRaw(raw []byte, pass string, d dest) {
+	buf := bufPool.Get().(*[]byte)
+	out := append((*buf)[:0], raw...)
+	*buf = out
+	if err := codec.Sign(out, b.r.sqn(), pass); err != nil {
+		bufPool.Put(buf)
+		b.r.log.send(erro, err)
+		return
+	}
+
+	var ip net.IP
+	if d.ip != 0 {
+		ip = uintToIP(d.ip)
+	}
+	b.push(out, buf, d.ifi, ip, d)
+}
+
+func (b *batch) push(raw []byte, buf *[]byte, ifi int, ip net.IP, d dest) {
+	b.ms = append(b.ms, Message{
+		Buf:  raw,
+		N:    len(raw),
+		Ifi:  ifi,
+		Addr: ip,
+		Src:  b.r.srcAddr(ifi, ip),
+	})
+	b.bufs = append(b.bufs, buf)
+
+	//n-th packet to every destination goes in n-th round
+	b.round = append(b.round, b.seen[d])
+	b.seen[d]++
+}
+
+// send writes batch at once, or round by round separated by
+// configured delay so multi-packet updates do not overrun receivers
+func (b *batch) send() {
+	if len(b.ms) == 0 {
+		return
+	}
+	delay := time.Duration(b.r.config().Global.PacketDelay) * time.Millisecond
+
+	go func() {
+		defer func() {
+			for _, buf := range b.bufs {
+				bufPool.Put(buf)
+			}
+		}()
+
+		if delay == 0 || len(b.seen) == len(b.ms) {
+			if _, err := b.r.trans.WriteBatch(b.ms); err != nil {
+				b.r.log.send(erro, err)
+			}
+			return
+		}
+
+		var rounds [][]Message
+		for i, m := range b.ms {
+			if b.round[i] == len(rounds) {
+				rounds = append(rounds, nil)
+			}
+			rounds[b.round[i]] = append(rounds[b.round[i]], m)
+		}
+		for i, round := range rounds {
+			if i > 0 {
+				select {
+				case <-time.After(delay):
+				case <-b.r.done:
+					return
+				}
+			}
+			if _, err := b.r.trans.WriteBatch(round); err != nil {
+				b.r.log.send(erro, err)
+			}
+		}
+	}()
+}
+
+func (r *Router) sqn() uint32 {
+	//Wall-clock at start advanced monotonically never goes back on clock steps
+	return uint32(r.start.Unix() + int64(r.clock.Now().Sub(r.start)/time.Second))
+}
+
+func (r *Router) sendPduAll(pds []*pdu) {
+	conf := r.config()
+	b := r.newBatch(len(pds))
+	for _, pdu := range pds {
+		if pdu.serviceFields.ifi != 0 {
+			ifi := pdu.serviceFields.ifi
+			ifc, _ := r.ifaces.get(ifi)
+			b.add(pdu, ifc.KeyChain.AuthKey, ifi, nil)
+		} else if pdu.serviceFields.ip != 0 {
+			ip := pdu.serviceFields.ip
+			b.add(pdu, conf.nbrs[ip].KeyChain.AuthKey, 0, uintToIP(ip))
+		}
+	}
+	b.send()
+}
+
+func (r *Router) reqGiveAll() {
+	pds := make([]*pdu, 0, 8)
+	pduTemp := pdu{
+		header:       header{Command: request, Version: 2},
+		routeEntries: []routeEntry{{Metric: infMetric}},
+	}
+
+	for ip, opt := range r.config().nbrs {
+		pdu := pduTemp
+		pdu.serviceFields = &serviceFields{ip: ip, authType: opt.KeyChain.AuthType}
+
+		pds = append(pds, &pdu)
+	}
+	for ifi, opt := range r.ifaces.list() {
+		if opt.Passive {
+			continue
+		}
+		pdu := pduTemp
+		pdu.serviceFields = &serviceFields{ifi: ifi, authType: opt.KeyChain.AuthType}
+
+		pds = append(pds, &pdu)
+	}
+
+	r.sendPduAll(pds)
+}
+
+func (r *Router) reqGiveIfi(ifi int) {
+	opt, ok := r.ifaces.get(ifi)
+	if !ok || opt.Passive {
+		return
+	}
+	req := &pdu{
+		header:        header{Command: request, Version: 2},
+		routeEntries:  []routeEntry{{Metric: infMetric}},
+		serviceFields: &serviceFields{ifi: ifi, authType: opt.KeyChain.AuthType},
+	}
+
+	r.sendPduAll([]*pdu{req})
+}
+
+func (r *Router) reqGiveNbr(ip uint32) {
+	opt, ok := r.config().nbrs[ip]
+	if !ok {
+		return
+	}
+	req := &pdu{
+		header:        header{Command: request, Version: 2},
+		routeEntries:  []routeEntry{{Metric: infMetric}},
+		serviceFields: &serviceFields{ip: ip, authType: opt.KeyChain.AuthType},
+	}
+
+	r.sendPduAll([]*pdu{req})
+}
+
+func (a *adjTable) respToGive(p *pdu) {
+	pds := make([]*pdu, 0, 8)
+
+	if _, ok := a.r.config().nbrs[p.serviceFields.ip]; ok {
+		pds = a.pduPerIP(!change, p.serviceFields.ip)
+		a.r.sendPduAll(pds)
+	} else {
+		pds = a.pduPerIfi(!change, p.serviceFields.ifi)
+		a.r.sendPduAll(pds)
+	}
+}
+
+func (a *adjTable) respToReq(p *pdu) {
+	a.mux.RLock()
+	for i := range p.routeEntries {
+		pEnt := &p.routeEntries[i]
+		netid := ipNet{IP: pEnt.Network, Mask: pEnt.Mask}
+		if e := a.entries.get(netid); e == nil {
+			pEnt.Metric = infMetric
+		} else {
+			pEnt.Metric = e.metric
+		}
+	}
+	a.mux.RUnlock()
+
+	p.header.Command = response
+	ip := p.serviceFields.ip
+	b := a.r.newBatch(1)
+	b.add(p, a.r.config().nbrs[ip].KeyChain.AuthKey, 0, uintToIP(ip))
+	b.send()
+}
+
+func (a *adjTable) respUpdate(change bool) {
+	pds := make([]*pdu, 0, 8)
+
+	for ip := range a.r.config().nbrs {
+		pds = append(pds, a.pduPerIP(change, ip)...)
+	}
+	for ifi, opt := range a.r.ifaces.list() {
+		if opt.Passive {
+			continue
+		}
+		pds = append(pds, a.pduPerIfi(change, ifi)...)
+	}
+
+	a.r.sendPduAll(pds)
+}
+
+// encoded is full table update for one destination as sent last time,
+// valid while table and keychain stay the same
+type encoded struct {
+	seq      uint64
+	keyChain KeyChain
+	size     int
+	pkts     [][]byte
+}
+
+// periodic sends full table to due destinations reusing encoded packets
+func (a *adjTable) periodic(due map[dest]bool) {
+	conf := a.r.config()
+	a.mux.RLock()
+	seq := a.seq
+	a.mux.RUnlock()
+
+	a.cacheMux.Lock()
+	defer a.cacheMux.Unlock()
+
+	b := a.r.newBatch(8)
+	next := make(map[dest]*encoded, len(a.cache))
+	use := func(d dest, kc KeyChain, build func() []*pdu) {
+		c, ok := a.cache[d]
+		if !due[d] {
+			if ok {
+				next[d] = c
+			}
+			return
+		}
+		if !ok || c.seq != seq || c.keyChain != kc || c.size != conf.Global.EntryCount {
+			c = &encoded{seq: seq, keyChain: kc, size: conf.Global.EntryCount}
+			for _, p := range build() {
+				raw, err := p.toByte(nil, kc.AuthKey, 0)
+				if err != nil {
+					a.r.log.send(erro, err)
+					continue
+				}
+				c.pkts = append(c.pkts, raw)
+			}
+		}
+		next[d] = c
+		for _, raw := range c.pkts {
+			b.addRaw(raw, kc.AuthKey, d)
+		}
+	}
+
+	for ip, nbr := range conf.nbrs {
+		ip := ip
+		use(dest{ip: ip}, nbr.KeyChain, func() []*pdu { return a.pduPerIP(!change, ip) })
+	}
+	for ifi, opt := range a.r.ifaces.list() {
+		if opt.Passive {
+			continue
+		}
+		ifi := ifi
+		use(dest{ifi: ifi}, opt.KeyChain, func() []*pdu { return a.pduPerIfi(!change, ifi) })
+	}
+
+	a.cache = next
+	b.send()
+}
+
+func (a *adjTable) pduPerIfi(change bool, ifi int) []*pdu {
+	ifc, _ := a.r.ifaces.get(ifi)
+	service := &serviceFields{
+		ifi:      ifi,
+		authType: ifc.KeyChain.AuthType,
+	}
+
+	filter := func(a *adj) bool { return a.ifi != ifi }
+	filtered := a.filterBy(dest{ifi: ifi}, filter, change)
+	return limitPduSize(a.r.config().Global.EntryCount, filtered, service)
+}
+
+func (a *adjTable) pduPerIP(change bool, ip uint32) []*pdu {
+	conf := a.r.config()
+	service := &serviceFields{
+		ip:       ip,
+		authType: conf.nbrs[ip].KeyChain.AuthType,
+	}
+
+	filter := func(a *adj) bool { return a.nextHop != ip }
+	filtered := a.filterBy(dest{ip: ip}, filter, change)
+	return limitPduSize(conf.Global.EntryCount, filtered, service)
+}
+
+// filterBy collects routes for d passing filter, with change only those changed
+// since last update to d. Change log position of d moves in the same critical
+// section, so concurrent changes are neither lost nor sent twice
+func (a *adjTable) filterBy(d dest, filter filtFunc, change bool) []routeEntry {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	filtered := make([]routeEntry, 0, 4)
+
+	var since uint64
+	if change {
+		since = a.sent[d]
+	}
+	a.entries.walk(func(net ipNet, opt *adj) {
+		if opt.seq <= since {
+			return
+		}
+		if filter(opt) {
+			routeEntry := routeEntry{
+				Network: net.IP,
+				Mask:    net.Mask,
+				Metric:  opt.metric,
+				AFI:     afiIPv4,
+			}
+			filtered = append(filtered, routeEntry)
+		}
+	})
+	a.sent[d] = a.seq
+	return filtered
+}
+
+func limitPduSize(size int, entList []routeEntry, service *serviceFields) []*pdu {
+	size -= authEntries[service.authType]
+	pds := make([]*pdu, 0, (len(entList)+size-1)/size)
+
+	for len(entList) > 0 {
+		n := size
+		if len(entList) < n {
+			n = len(entList)
+		}
+		pds = append(pds, &pdu{
+			serviceFields: service,
+			header:        header{Command: response, Version: 2},
+			routeEntries:  entList[:n:n],
+		})
+		entList = entList[n:]
+	}
+	return pds
+}
@@ -0,0 +1,23 @@
+package rip
+
+import "testing"
+
+func benchmarkToByte(b *testing.B, authType uint16) {
+	p := benchPdu(25-authEntries[authType], authType)
+	buf := make([]byte, 0, 512)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw, err := p.toByte(buf[:0], benchKey, uint32(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = raw
+	}
+}
+
+func BenchmarkToByte(b *testing.B) {
+	b.Run("plain", func(b *testing.B) { benchmarkToByte(b, authNon) })
+	b.Run("hash", func(b *testing.B) { benchmarkToByte(b, authHash) })
+}
@@ -0,0 +1,164 @@
+package rip
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// Message is a single datagram, Ifi and Addr are the receiving interface and
+// source on read, outgoing interface and destination on write.
+// Nil Addr on write means RIP multicast group, nil Src leaves source to kernel
+type Message struct {
+	Buf  []byte
+	N    int
+	Ifi  int
+	Addr net.IP
+	Src  net.IP
+}
+
+// Transport carries RIP datagrams
+type Transport interface {
+	ReadBatch(ms []Message) (int, error)
+	WriteBatch(ms []Message) (int, error)
+	Join(ifi int) error
+	Leave(ifi int) error
+	Close() error
+}
+
+var ripGroup = net.IPv4(224, 0, 0, 9)
+
+type socket struct {
+	connect *ipv4.PacketConn
+	//Read side is owned by single receiver
+	rx  []ipv4.Message
+	rcm []ipv4.ControlMessage
+}
+
+// NewSocket opens UDP socket on RIP port
+func NewSocket() (Transport, error) {
+	s, err := net.ListenPacket("udp4", "0.0.0.0:520")
+	if err != nil {
+		return nil, err
+	}
+
+	p := ipv4.NewPacketConn(s)
+
+	p.SetTOS(0xc0)
+	p.SetMulticastTTL(1)
+
+	if err := p.SetControlMessage(ipv4.FlagDst, true); err != nil {
+		return nil, err
+	}
+
+	socket := &socket{
+		connect: p,
+	}
+
+	return socket, nil
+}
+
+func (s *socket) ReadBatch(ms []Message) (int, error) {
+	if len(s.rx) < len(ms) {
+		s.rx = make([]ipv4.Message, len(ms))
+		s.rcm = make([]ipv4.ControlMessage, len(ms))
+		for i := range s.rx {
+			s.rx[i].Buffers = make([][]byte, 1)
+			s.rx[i].OOB = ipv4.NewControlMessage(ipv4.FlagDst)
+		}
+	}
+
+	rx := s.rx[:len(ms)]
+	for i := range rx {
+		rx[i].Buffers[0] = ms[i].Buf
+		rx[i].OOB = rx[i].OOB[:cap(rx[i].OOB)]
+	}
+
+	n, err := s.connect.ReadBatch(rx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		ms[i].N = rx[i].N
+		ms[i].Ifi = 0
+		ms[i].Addr = nil
+		if udp, ok := rx[i].Addr.(*net.UDPAddr); ok {
+			ms[i].Addr = udp.IP
+		}
+		cm := &s.rcm[i]
+		if rx[i].NN > 0 && cm.Parse(rx[i].OOB[:rx[i].NN]) == nil {
+			ms[i].Ifi = cm.IfIndex
+		}
+	}
+	return n, nil
+}
+
+func (s *socket) WriteBatch(ms []Message) (int, error) {
+	tx := make([]ipv4.Message, len(ms))
+	for i, m := range ms {
+		dst := m.Addr
+		if dst == nil {
+			dst = ripGroup
+		}
+		//IP_PKTINFO picks interface and source per packet, no socket state is touched
+		cm := ipv4.ControlMessage{IfIndex: m.Ifi, Src: m.Src}
+		tx[i] = ipv4.Message{
+			Buffers: [][]byte{m.Buf},
+			OOB:     cm.Marshal(),
+			Addr:    &net.UDPAddr{IP: dst, Port: 520},
+		}
+	}
+
+	//sendmmsg stops at first failing message, skip it so one interface
+	//being down does not drop the rest of the batch
+	var (
+		sent, written int
+		errs          []error
+	)
+	for sent < len(tx) {
+		n, err := s.connect.WriteBatch(tx[sent:], 0)
+		if n > 0 {
+			written += n
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if n < 0 {
+				n = 0
+			}
+			sent += n + 1
+			continue
+		}
+		sent += n
+	}
+	return written, errors.Join(errs...)
+}
+
+func (s *socket) Join(ifn int) error {
+	group := net.UDPAddr{IP: ripGroup}
+
+	ifi, err := net.InterfaceByIndex(ifn)
+	if err != nil {
+		return err
+	}
+	if err := s.connect.JoinGroup(ifi, &group); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *socket) Leave(ifn int) error {
+	group := net.UDPAddr{IP: ripGroup}
+
+	//Kernel drops membership by itself when interface is gone
+	ifi, err := net.InterfaceByIndex(ifn)
+	if err != nil {
+		return err
+	}
+	return s.connect.LeaveGroup(ifi, &group)
+}
+
+func (s *socket) Close() error {
+	return s.connect.Close()
+}
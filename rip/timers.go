@@ -0,0 +1,141 @@
+package rip
+
+import (
+	"container/heap"
+	"time"
+)
+
+// routeTimer is a pending timeout or garbage collection of one route
+type routeTimer struct {
+	at    time.Time
+	net   ipNet
+	index int
+}
+
+type timerHeap []*routeTimer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*routeTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// arm (re)schedules expiry of e after d, caller holds a.mux
+func (a *adjTable) arm(netid ipNet, e *adj, d time.Duration) {
+	at := a.r.clock.Now().Add(d)
+	if e.timer == nil {
+		e.timer = &routeTimer{at: at, net: netid}
+		heap.Push(&a.timers, e.timer)
+	} else {
+		e.timer.at = at
+		heap.Fix(&a.timers, e.timer.index)
+	}
+	if e.timer.index == 0 {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// disarm cancels pending expiry of e, caller holds a.mux
+func (a *adjTable) disarm(e *adj) {
+	if e.timer != nil {
+		heap.Remove(&a.timers, e.timer.index)
+		e.timer = nil
+	}
+}
+
+// timers returns effective timers of routes learned via ifi
+func (r *Router) timers(ifi int) Timers {
+	ifc, _ := r.ifaces.get(ifi)
+	return r.config().timers(ifc)
+}
+
+func (a *adjTable) timeout(e *adj) time.Duration {
+	return time.Duration(a.r.timers(e.ifi).TimeoutTimer)
+}
+
+func (a *adjTable) garbage(e *adj) time.Duration {
+	return time.Duration(a.r.timers(e.ifi).GarbageTimer)
+}
+
+// kill starts garbage collection of a route, caller holds a.mux
+func (a *adjTable) kill(netid ipNet, e *adj) {
+	e.metric = infMetric
+	e.kill = true
+	a.touch(e)
+	a.arm(netid, e, a.garbage(e))
+}
+
+// expirer fires route timers exactly when due
+func (a *adjTable) expirer() {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+	for {
+		a.mux.Lock()
+		next := a.expire()
+		a.mux.Unlock()
+
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(next)
+
+		select {
+		case <-t.C:
+		case <-a.wake:
+		case <-a.r.done:
+			return
+		}
+	}
+}
+
+// expire handles due timers and returns time until the next one
+func (a *adjTable) expire() time.Duration {
+	now := a.r.clock.Now()
+	for len(a.timers) > 0 {
+		if wait := a.timers[0].at.Sub(now); wait > 0 {
+			return wait
+		}
+		rt := heap.Pop(&a.timers).(*routeTimer)
+		e := a.entries.get(rt.net)
+		if e == nil {
+			continue
+		}
+		e.timer = nil
+
+		if !e.kill {
+			a.kill(rt.net, e)
+			continue
+		}
+		if err := a.r.remRoute(rt.net); err != nil {
+			a.r.log.send(erro, err)
+		}
+		a.entries.delete(rt.net)
+		//Deletion is a change too, encoded full updates must not keep the route
+		a.seq++
+	}
+	return time.Hour
+}
@@ -0,0 +1,198 @@
+package rip
+
+import "math/bits"
+
+// trie is a path-compressed binary prefix trie of adjustments,
+// nodes without value are glue and always have both children
+type trie struct {
+	root *node
+	size int
+}
+
+type node struct {
+	net   ipNet
+	bits  int
+	child [2]*node
+	val   *adj
+}
+
+func newTrie() *trie {
+	return &trie{}
+}
+
+func maskLen(mask uint32) int {
+	return bits.LeadingZeros32(^mask)
+}
+
+func maskOf(l int) uint32 {
+	return ^uint32(0) << (32 - l)
+}
+
+// validNet reports whether mask is contiguous and network has no host bits
+func validNet(n ipNet) bool {
+	return n.Mask == maskOf(maskLen(n.Mask)) && n.IP&^n.Mask == 0
+}
+
+func bitAt(ip uint32, pos int) int {
+	return int(ip>>(31-pos)) & 1
+}
+
+func commonLen(a uint32, al int, b uint32, bl int) int {
+	l := bits.LeadingZeros32(a ^ b)
+	if al < l {
+		l = al
+	}
+	if bl < l {
+		l = bl
+	}
+	return l
+}
+
+func (t *trie) len() int {
+	return t.size
+}
+
+// get returns exact match
+func (t *trie) get(p ipNet) *adj {
+	l := maskLen(p.Mask)
+	for n := t.root; n != nil && n.bits <= l; n = n.child[bitAt(p.IP, n.bits)] {
+		if commonLen(n.net.IP, n.bits, p.IP, l) < n.bits {
+			return nil
+		}
+		if n.bits == l {
+			return n.val
+		}
+	}
+	return nil
+}
+
+// lookup returns the longest prefix covering ip
+func (t *trie) lookup(ip uint32) (ipNet, *adj) {
+	var (
+		best ipNet
+		val  *adj
+	)
+	for n := t.root; n != nil; n = n.child[bitAt(ip, n.bits)] {
+		if commonLen(n.net.IP, n.bits, ip, 32) < n.bits {
+			break
+		}
+		if n.val != nil {
+			best, val = n.net, n.val
+		}
+		if n.bits == 32 {
+			break
+		}
+	}
+	return best, val
+}
+
+func (t *trie) insert(p ipNet, v *adj) {
+	l := maskLen(p.Mask)
+	np := &t.root
+	for {
+		n := *np
+		if n == nil {
+			*np = &node{net: p, bits: l, val: v}
+			t.size++
+			return
+		}
+
+		common := commonLen(n.net.IP, n.bits, p.IP, l)
+		switch {
+		case common == n.bits && common == l:
+			if n.val == nil {
+				t.size++
+			}
+			n.val = v
+			return
+		case common == n.bits:
+			np = &n.child[bitAt(p.IP, n.bits)]
+			continue
+		case common == l:
+			//New prefix covers n
+			nn := &node{net: p, bits: l, val: v}
+			nn.child[bitAt(n.net.IP, l)] = n
+			*np = nn
+		default:
+			g := &node{net: ipNet{IP: p.IP & maskOf(common), Mask: maskOf(common)}, bits: common}
+			g.child[bitAt(n.net.IP, common)] = n
+			g.child[bitAt(p.IP, common)] = &node{net: p, bits: l, val: v}
+			*np = g
+		}
+		t.size++
+		return
+	}
+}
+
+func (t *trie) delete(p ipNet) {
+	l := maskLen(p.Mask)
+	var parent **node
+	np := &t.root
+	for n := *np; n != nil && n.bits <= l; n = *np {
+		if commonLen(n.net.IP, n.bits, p.IP, l) < n.bits {
+			return
+		}
+		if n.bits < l {
+			parent = np
+			np = &n.child[bitAt(p.IP, n.bits)]
+			continue
+		}
+
+		if n.val == nil {
+			return
+		}
+		n.val = nil
+		t.size--
+		switch {
+		case n.child[0] != nil && n.child[1] != nil:
+		case n.child[0] != nil:
+			*np = n.child[0]
+		case n.child[1] != nil:
+			*np = n.child[1]
+		default:
+			*np = nil
+			//Glue parent is left with a single child
+			if parent != nil && (*parent).val == nil {
+				pn := *parent
+				if pn.child[0] != nil {
+					*parent = pn.child[0]
+				} else {
+					*parent = pn.child[1]
+				}
+			}
+		}
+		return
+	}
+}
+
+// walk visits all routes in prefix order
+func (t *trie) walk(fn func(ipNet, *adj)) {
+	t.root.walk(fn)
+}
+
+// subtree visits routes covered by p including p itself
+func (t *trie) subtree(p ipNet, fn func(ipNet, *adj)) {
+	l := maskLen(p.Mask)
+	for n := t.root; n != nil; n = n.child[bitAt(p.IP, n.bits)] {
+		if n.bits >= l {
+			if commonLen(n.net.IP, n.bits, p.IP, l) == l {
+				n.walk(fn)
+			}
+			return
+		}
+		if commonLen(n.net.IP, n.bits, p.IP, l) < n.bits {
+			return
+		}
+	}
+}
+
+func (n *node) walk(fn func(ipNet, *adj)) {
+	if n == nil {
+		return
+	}
+	if n.val != nil {
+		fn(n.net, n.val)
+	}
+	n.child[0].walk(fn)
+	n.child[1].walk(fn)
+}
@@ -0,0 +1,261 @@
+package rip
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randNet(rnd *rand.Rand) ipNet {
+	//Few short prefixes in a narrow range give plenty of overlaps
+	l := 8 + rnd.Intn(25)
+	ip := 10<<24 | rnd.Uint32()&0x00ff0fff
+	return ipNet{IP: ip & maskOf(l), Mask: maskOf(l)}
+}
+
+func contains(p ipNet, ip uint32) bool {
+	return ip&p.Mask == p.IP
+}
+
+func collect(fn func(func(ipNet, *adj))) []ipNet {
+	var nets []ipNet
+	fn(func(n ipNet, _ *adj) { nets = append(nets, n) })
+	return nets
+}
+
+// check compares trie with map reference and verifies glue invariant
+func check(t *testing.T, tr *trie, ref map[ipNet]*adj) {
+	t.Helper()
+	if tr.len() != len(ref) {
+		t.Fatalf("len %d, want %d", tr.len(), len(ref))
+	}
+	var walk func(n *node, bits int)
+	walk = func(n *node, bits int) {
+		if n == nil {
+			return
+		}
+		if n.bits <= bits && bits >= 0 {
+			t.Fatalf("node %v/%d under %d bits", n.net, n.bits, bits)
+		}
+		if n.val == nil && (n.child[0] == nil || n.child[1] == nil) {
+			t.Fatalf("glue %v/%d with single child", n.net, n.bits)
+		}
+		walk(n.child[0], n.bits)
+		walk(n.child[1], n.bits)
+	}
+	walk(tr.root, -1)
+
+	nets := collect(tr.walk)
+	if len(nets) != len(ref) {
+		t.Fatalf("walk visited %d, want %d", len(nets), len(ref))
+	}
+	for _, n := range nets {
+		if ref[n] == nil || tr.get(n) != ref[n] {
+			t.Fatalf("get %v mismatch", n)
+		}
+	}
+}
+
+func TestTrieRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tr := newTrie()
+	ref := make(map[ipNet]*adj)
+
+	for i := 0; i < 20000; i++ {
+		p := randNet(rnd)
+		if rnd.Intn(3) == 0 {
+			tr.delete(p)
+			delete(ref, p)
+		} else {
+			v := &adj{metric: uint32(i)}
+			tr.insert(p, v)
+			ref[p] = v
+		}
+		if tr.get(p) != ref[p] {
+			t.Fatalf("get %v after change mismatch", p)
+		}
+
+		//Reference lookup scans all routes, sample it
+		if i%8 != 0 {
+			continue
+		}
+		ip := 10<<24 | rnd.Uint32()&0x00ff0fff
+		var (
+			best ipNet
+			want *adj
+		)
+		for n, v := range ref {
+			if contains(n, ip) && (want == nil || maskLen(n.Mask) > maskLen(best.Mask)) {
+				best, want = n, v
+			}
+		}
+		if got, v := tr.lookup(ip); v != want || (want != nil && got != best) {
+			t.Fatalf("lookup %v: got %v, want %v", uintToIP(ip), got, best)
+		}
+
+		if i%1000 == 0 {
+			check(t, tr, ref)
+			sub := randNet(rnd)
+			var exp []ipNet
+			for n := range ref {
+				if maskLen(n.Mask) >= maskLen(sub.Mask) && contains(sub, n.IP) {
+					exp = append(exp, n)
+				}
+			}
+			got := collect(func(fn func(ipNet, *adj)) { tr.subtree(sub, fn) })
+			less := func(s []ipNet) func(i, j int) bool {
+				return func(i, j int) bool {
+					if s[i].IP != s[j].IP {
+						return s[i].IP < s[j].IP
+					}
+					return s[i].Mask < s[j].Mask
+				}
+			}
+			sort.Slice(exp, less(exp))
+			sort.Slice(got, less(got))
+			if len(got) != len(exp) {
+				t.Fatalf("subtree %v: %d routes, want %d", sub, len(got), len(exp))
+			}
+			for j := range got {
+				if got[j] != exp[j] {
+					t.Fatalf("subtree %v: got %v, want %v", sub, got[j], exp[j])
+				}
+			}
+		}
+	}
+	check(t, tr, ref)
+
+	for n := range ref {
+		tr.delete(n)
+	}
+	if tr.root != nil || tr.len() != 0 {
+		t.Fatalf("trie not empty after deleting all routes")
+	}
+}
+
+func TestTrieGlue(t *testing.T) {
+	tr := newTrie()
+	a := ipNet{IP: 10<<24 | 1<<8, Mask: maskOf(24)}
+	b := ipNet{IP: 10<<24 | 2<<8, Mask: maskOf(24)}
+	tr.insert(a, &adj{})
+	tr.insert(b, &adj{})
+	if tr.root.val != nil || tr.root.bits != 22 {
+		t.Fatalf("expected glue /22 root, got %v/%d", tr.root.net, tr.root.bits)
+	}
+
+	//Covering prefix takes over glue
+	c := ipNet{IP: 10 << 24, Mask: maskOf(22)}
+	tr.insert(c, &adj{})
+	if tr.root.val == nil || tr.len() != 3 {
+		t.Fatalf("glue not filled by %v", c)
+	}
+	tr.delete(c)
+	if tr.root.val != nil || tr.len() != 2 {
+		t.Fatalf("deleted %v left value", c)
+	}
+
+	//Removing a leaf collapses glue into the sibling
+	tr.delete(a)
+	if tr.root.net != b || tr.root.child[0] != nil || tr.root.child[1] != nil {
+		t.Fatalf("glue not collapsed, root %v/%d", tr.root.net, tr.root.bits)
+	}
+	tr.delete(b)
+	if tr.root != nil {
+		t.Fatalf("trie not empty")
+	}
+}
+
+func TestValidNet(t *testing.T) {
+	for _, tc := range []struct {
+		n  ipNet
+		ok bool
+	}{
+		{ipNet{IP: 10 << 24, Mask: maskOf(8)}, true},
+		{ipNet{IP: 10<<24 | 1, Mask: maskOf(8)}, false},
+		{ipNet{IP: 10 << 24, Mask: 0xff00ff00}, false},
+		{ipNet{}, true},
+	} {
+		if validNet(tc.n) != tc.ok {
+			t.Errorf("validNet(%v) = %v, want %v", tc.n, !tc.ok, tc.ok)
+		}
+	}
+}
+
+func benchNets(n int) []ipNet {
+	rnd := rand.New(rand.NewSource(int64(n)))
+	nets := make([]ipNet, n)
+	for i := range nets {
+		l := 16 + rnd.Intn(17)
+		ip := rnd.Uint32()
+		nets[i] = ipNet{IP: ip & maskOf(l), Mask: maskOf(l)}
+	}
+	return nets
+}
+
+func benchTrie(nets []ipNet) *trie {
+	tr := newTrie()
+	for _, n := range nets {
+		tr.insert(n, &adj{})
+	}
+	return tr
+}
+
+var benchSizes = []struct {
+	name string
+	n    int
+}{{"10k", 10000}, {"100k", 100000}}
+
+func BenchmarkTrieInsert(b *testing.B) {
+	for _, s := range benchSizes {
+		nets := benchNets(s.n)
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				benchTrie(nets)
+			}
+		})
+	}
+}
+
+func BenchmarkTrieGet(b *testing.B) {
+	for _, s := range benchSizes {
+		nets := benchNets(s.n)
+		tr := benchTrie(nets)
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tr.get(nets[i%len(nets)])
+			}
+		})
+	}
+}
+
+func BenchmarkTrieLookup(b *testing.B) {
+	for _, s := range benchSizes {
+		tr := benchTrie(benchNets(s.n))
+		rnd := rand.New(rand.NewSource(1))
+		ips := make([]uint32, 1024)
+		for i := range ips {
+			ips[i] = rnd.Uint32()
+		}
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tr.lookup(ips[i%len(ips)])
+			}
+		})
+	}
+}
+
+func BenchmarkTrieWalk(b *testing.B) {
+	for _, s := range benchSizes {
+		tr := benchTrie(benchNets(s.n))
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var n int
+				tr.walk(func(ipNet, *adj) { n++ })
+			}
+		})
+	}
+}
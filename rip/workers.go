@@ -0,0 +1,147 @@
+package rip
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+const (
+	maxEntryCount = 255
+	rxBatch       = 16
+)
+
+// Room for auth entries and one more byte to catch truncated datagrams
+const rxSize = (maxEntryCount+2)*entrySize + headerSize + 1
+
+var errQueueFull = errors.New("receive queue full")
+
+var rxPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, rxSize)
+		return &b
+	},
+}
+
+type job struct {
+	buf *[]byte
+	n   int
+	ifi int
+	src uint32
+}
+
+type workers struct {
+	r      *Router
+	queues []chan job
+}
+
+func (r *Router) initWorkers() *workers {
+	w := &workers{r: r}
+	conf := r.config()
+	w.queues = make([]chan job, conf.Global.Workers)
+	for i := range w.queues {
+		w.queues[i] = make(chan job, conf.Global.QueueSize)
+		q := w.queues[i]
+		r.spawn(func() { w.worker(q) })
+	}
+	return w
+}
+
+// dispatch keeps packets of one source on one worker to preserve their order
+// and reports whether worker took ownership of the buffer
+func (w *workers) dispatch(j job) bool {
+	q := w.queues[j.src%uint32(len(w.queues))]
+	select {
+	case q <- j:
+		return true
+	default:
+		w.r.rx.count(j.ifi, errQueueFull)
+		return false
+	}
+}
+
+func (w *workers) worker(q chan job) {
+	var st rxState
+	for {
+		select {
+		case j := <-q:
+			w.r.handle(&st, (*j.buf)[:j.n], j.ifi, j.src)
+			rxPool.Put(j.buf)
+		case <-w.r.done:
+			return
+		}
+	}
+}
+
+func (r *Router) receive() {
+	bufs := make([]*[]byte, rxBatch)
+	ms := make([]Message, rxBatch)
+	for i := range ms {
+		bufs[i] = rxPool.Get().(*[]byte)
+		ms[i].Buf = *bufs[i]
+	}
+
+	for {
+		n, err := r.trans.ReadBatch(ms)
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		if err != nil {
+			r.log.send(fatal, err)
+			go r.stop(err)
+			return
+		}
+
+		for i := 0; i < n; i++ {
+			m := &ms[i]
+			ip := m.Addr.To4()
+			if ip == nil {
+				continue
+			}
+			if m.N == len(m.Buf) {
+				r.rx.count(m.Ifi, errOversize)
+				continue
+			}
+
+			//Buffer goes to worker, slot gets a fresh one
+			if r.workers.dispatch(job{
+				buf: bufs[i],
+				n:   m.N,
+				ifi: m.Ifi,
+				src: binary.BigEndian.Uint32(ip),
+			}) {
+				bufs[i] = rxPool.Get().(*[]byte)
+				m.Buf = *bufs[i]
+			}
+		}
+	}
+}
+
+// handle processes packet synchronously, b and st are reused after return
+func (r *Router) handle(st *rxState, b []byte, ifi int, src uint32) {
+	conf := r.config()
+	packet, err := r.readPacket(conf, b, ifi, src)
+	if err != nil {
+		//Drop weird sourced packet
+		r.rx.count(ifi, err)
+		return
+	}
+
+	pdu, err := packet.parse(st, conf.Global.EntryCount)
+	if err != nil {
+		r.rx.count(ifi, err)
+		r.log.send(warn, err.Error()+" from "+uintToIP(src).String())
+		return
+	}
+	r.log.send(debug, pdu)
+
+	if err = pdu.validate(packet.keyChain, r.log); err != nil {
+		r.rx.count(ifi, err)
+		r.log.send(warn, err)
+	} else {
+		r.nbr.update(pdu.serviceFields.ip, pdu.serviceFields.ifi)
+		r.adj.procIncom(pdu)
+	}
+}